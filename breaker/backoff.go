@@ -0,0 +1,84 @@
+package breaker
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+type Jitter uint8
+
+const (
+	NOJITTER Jitter = iota
+
+	// Opens for a random duration up to the backed off one.
+	FULLJITTER
+
+	// Opens for a random duration between RecoverInterval and the previous
+	// open duration times RecoverBackoff.
+	DECORRELATEDJITTER
+)
+
+var jitterNames = []string{
+	NOJITTER:           "none",
+	FULLJITTER:         "full",
+	DECORRELATEDJITTER: "decorrelated",
+}
+
+func (j Jitter) String() string {
+	return name(jitterNames, uint8(j))
+}
+
+func (j Jitter) MarshalText() ([]byte, error) {
+	return []byte(j.String()), nil
+}
+
+func (j *Jitter) UnmarshalText(text []byte) error {
+	res, err := parse(jitterNames, "jitter", text)
+	if err == nil {
+		*j = Jitter(res)
+	}
+
+	return err
+}
+
+// openDuration returns how long to stay OPEN after the given number of
+// consecutive failed recoveries, prev being the last open duration.
+func (o MetricsOptions) openDuration(failures uint32, prev time.Duration) time.Duration {
+	base := float64(o.RecoverInterval)
+	limit := float64(math.MaxInt64)
+	if o.MaxRecoverInterval > 0 {
+		limit = float64(o.MaxRecoverInterval)
+	}
+
+	multiplier := o.RecoverBackoff
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	var d float64
+
+	switch o.RecoverJitter {
+	case DECORRELATEDJITTER:
+		d = base
+		if failures > 0 {
+			high := math.Max(float64(prev)*multiplier, base)
+			d = base + rand.Float64()*(high-base)
+		}
+	default:
+		d = base * math.Pow(multiplier, float64(failures))
+	}
+
+	d = math.Min(d, limit)
+
+	if o.RecoverJitter == FULLJITTER {
+		d *= 1 - rand.Float64()
+	}
+
+	// float64(math.MaxInt64) rounds up to 2^63, which no Duration can hold.
+	if d >= float64(math.MaxInt64) {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(d)
+}
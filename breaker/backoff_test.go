@@ -0,0 +1,120 @@
+package breaker
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestMetricsOptionsMethodOpenDuration(t *testing.T) {
+	o := MetricsOptions{RecoverInterval: time.Second}
+
+	for failures := uint32(0); failures < 3; failures++ {
+		if d := o.openDuration(failures, time.Second); d != time.Second {
+			t.Fatalf("open duration should not back off by default, got: %v", d)
+		}
+	}
+
+	o.RecoverBackoff = 2
+	o.MaxRecoverInterval = 5 * time.Second
+
+	for failures, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if d := o.openDuration(uint32(failures), 0); d != want {
+			t.Fatalf("open duration after %d failures should be %v, got: %v", failures, want, d)
+		}
+	}
+
+	o.RecoverJitter = FULLJITTER
+
+	for i := 0; i < 100; i++ {
+		if d := o.openDuration(2, 0); d <= 0 || d > 4*time.Second {
+			t.Fatalf("full jitter should be within (0, 4s], got: %v", d)
+		}
+	}
+
+	o.RecoverJitter = DECORRELATEDJITTER
+
+	if d := o.openDuration(0, 0); d != time.Second {
+		t.Fatalf("decorrelated jitter should start at the recover interval, got: %v", d)
+	}
+	for i := 0; i < 100; i++ {
+		if d := o.openDuration(1, 2*time.Second); d < time.Second || d > 4*time.Second {
+			t.Fatalf("decorrelated jitter should be within [1s, 4s], got: %v", d)
+		}
+		if d := o.openDuration(1, 4*time.Second); d > 5*time.Second {
+			t.Fatalf("decorrelated jitter should be capped, got: %v", d)
+		}
+	}
+}
+
+func TestBreakerShouldBackOffOnFailedRecoveries(t *testing.T) {
+	b := newBreaker(MetricsOptions{RecoverInterval: time.Minute, RecoverBackoff: 2}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if b.State() == CLOSED {
+			b.changeState(OPEN)
+		} else {
+			b.changeState(HALFOPEN)
+			b.changeState(OPEN)
+		}
+
+		res := b.Snapshot()
+		if res.OpenFor != want || res.RecoverAt.Sub(res.Since) < want {
+			t.Fatalf("open duration should be %v, got: %v until %v", want, res.OpenFor, res.RecoverAt)
+		}
+	}
+
+	b.changeState(HALFOPEN)
+	b.changeState(CLOSED)
+
+	if res := b.Snapshot(); res.OpenFor != 0 {
+		t.Fatalf("open duration should be reset once CLOSED, got: %v", res.OpenFor)
+	}
+
+	b.changeState(OPEN)
+
+	if res := b.Snapshot(); res.OpenFor != time.Minute {
+		t.Fatalf("backoff should restart after CLOSED, got: %v", res.OpenFor)
+	}
+}
+
+func TestJitterMethodMarshalText(t *testing.T) {
+	for _, j := range []Jitter{NOJITTER, FULLJITTER, DECORRELATEDJITTER} {
+		text, _ := j.MarshalText()
+
+		var res Jitter
+		if err := res.UnmarshalText(text); err != nil || res != j {
+			t.Fatalf("jitter should round trip %v, got: %v, %v", j, res, err)
+		}
+	}
+
+	var res Jitter
+	if err := res.UnmarshalText([]byte("test")); err == nil {
+		t.Fatalf("unknown jitter should fail")
+	}
+}
+
+func TestBreakerShouldNotOverflowOpenDurationWithoutMaximum(t *testing.T) {
+	b := newBreaker(MetricsOptions{RecoverInterval: time.Second, RecoverBackoff: 1000}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	b.changeState(OPEN)
+
+	for i := 0; i < 40; i++ {
+		b.changeState(HALFOPEN)
+		b.changeState(OPEN)
+
+		res := b.Snapshot()
+		if res.OpenFor <= 0 || !res.RecoverAt.After(res.Since) {
+			t.Fatalf("open duration after %d reopens should be positive, got: %v until %v", i+1, res.OpenFor, res.RecoverAt)
+		}
+	}
+
+	for _, jitter := range []Jitter{NOJITTER, DECORRELATEDJITTER} {
+		o := MetricsOptions{RecoverInterval: time.Second, RecoverBackoff: 2, RecoverJitter: jitter}
+		if d := o.openDuration(34, time.Duration(math.MaxInt64)); d <= 0 {
+			t.Fatalf("open duration with %s jitter should saturate, got: %v", jitter, d)
+		}
+	}
+}
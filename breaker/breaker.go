@@ -2,31 +2,22 @@ package breaker
 
 import (
 	"context"
-	// "fmt"
+	"math/bits"
+	"math/rand/v2"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-const (
-	SUCCEED = 0
-	FAILED  = 1
-	TIMEOUT = 2
-	REJECT  = 3
-
-	CLOSED   = 0
-	OPEN     = 1
-	HALFOPEN = 2
-
-	HOLD  = 0
-	TRUE  = 1
-	FALSE = 2
-)
-
 type bucket struct {
 	succeed uint32
 	failed  uint32
 	timeout uint32
 	reject  uint32
+	slow    uint32
+	short   uint32
+	latency *histogram
 }
 
 func newBucket() *bucket {
@@ -38,22 +29,183 @@ func (b *bucket) reset() {
 	b.failed = 0
 	b.timeout = 0
 	b.reject = 0
-}
-
-func (b *bucket) faileds() int {
-	return int(b.failed + b.timeout + b.reject)
+	b.slow = 0
+	b.short = 0
+	if b.latency != nil {
+		b.latency.reset()
+	}
 }
 
 func (b *bucket) all() int {
 	return int(b.succeed + b.failed + b.timeout + b.reject)
 }
 
+func (b *bucket) merge(o bucket) {
+	b.succeed += o.succeed
+	b.failed += o.failed
+	b.timeout += o.timeout
+	b.reject += o.reject
+	b.slow += o.slow
+	b.short += o.short
+	if o.latency != nil {
+		if b.latency == nil {
+			b.latency = &histogram{}
+		}
+		b.latency.merge(o.latency)
+	}
+}
+
+func (b *bucket) counts() Counts {
+	return Counts{
+		Succeeded: b.succeed,
+		Failed:    b.failed,
+		TimedOut:  b.timeout,
+		Rejected:  b.reject,
+		Slow:      b.slow,
+
+		ShortCircuited: b.short,
+	}
+}
+
+// Slow calls and calls rejected by the breaker are counted next to the
+// outcomes.
+const (
+	slowCounter  = int(REJECT) + 1
+	shortCounter = slowCounter + 1
+)
+
+type shard struct {
+	counts  [shortCounter + 1]uint32
+	latency atomic.Pointer[histogram]
+	_       [32]byte
+}
+
+var shardMask = uint32(1)<<bits.Len(uint(runtime.GOMAXPROCS(0)-1)) - 1
+
+// epoch collects the outcomes recorded since the last state change. They
+// are spread over padded shards so that concurrent callers do not contend,
+// and outcomes of calls admitted by an earlier epoch are simply lost.
+type epoch struct {
+	state  State
+	since  time.Time
+	probes int32
+	streak uint64
+	shards []shard
+}
+
+func newEpoch(state State) *epoch {
+	return &epoch{
+		state:  state,
+		since:  time.Now(),
+		shards: make([]shard, shardMask+1),
+	}
+}
+
+func (e *epoch) add(outcome Outcome) {
+	if outcome > REJECT {
+		return
+	}
+
+	atomic.AddUint32(&e.shards[rand.Uint32()&shardMask].counts[outcome], 1)
+}
+
+func (e *epoch) addSlow() {
+	atomic.AddUint32(&e.shards[rand.Uint32()&shardMask].counts[slowCounter], 1)
+}
+
+func (e *epoch) addShort() {
+	atomic.AddUint32(&e.shards[rand.Uint32()&shardMask].counts[shortCounter], 1)
+}
+
+// addLatency allocates the shard's histogram on first use, so that idle
+// breakers stay small.
+func (e *epoch) addLatency(d time.Duration) {
+	s := &e.shards[rand.Uint32()&shardMask]
+
+	h := s.latency.Load()
+	if h == nil {
+		s.latency.CompareAndSwap(nil, &histogram{})
+		h = s.latency.Load()
+	}
+
+	h.record(d)
+}
+
+func (e *epoch) drain() bucket {
+	var res bucket
+
+	e.drainInto(&res)
+	return res
+}
+
+// drainInto moves the recorded outcomes into dst and returns their counts.
+func (e *epoch) drainInto(dst *bucket) Counts {
+	var res Counts
+
+	for i := range e.shards {
+		counts := &e.shards[i].counts
+		res.Succeeded += atomic.SwapUint32(&counts[SUCCEED], 0)
+		res.Failed += atomic.SwapUint32(&counts[FAILED], 0)
+		res.TimedOut += atomic.SwapUint32(&counts[TIMEOUT], 0)
+		res.Rejected += atomic.SwapUint32(&counts[REJECT], 0)
+		res.Slow += atomic.SwapUint32(&counts[slowCounter], 0)
+		res.ShortCircuited += atomic.SwapUint32(&counts[shortCounter], 0)
+
+		if h := e.shards[i].latency.Load(); h != nil && atomic.LoadUint32(&h.total) > 0 {
+			if dst.latency == nil {
+				dst.latency = &histogram{}
+			}
+			h.drainInto(dst.latency)
+		}
+	}
+
+	dst.succeed += res.Succeeded
+	dst.failed += res.Failed
+	dst.timeout += res.TimedOut
+	dst.reject += res.Rejected
+	dst.slow += res.Slow
+	dst.short += res.ShortCircuited
+
+	return res
+}
+
 type MetricsOptions struct {
+	Name string
+
 	MetricsRollingCount uint8
 	MetricsInterval     time.Duration
 	ReceiveInterval     time.Duration
 	UpdateStateInterval time.Duration
 	RecoverInterval     time.Duration
+
+	// Multiplies the open duration on every failed recovery in a row, up
+	// to MaxRecoverInterval when set. Zero or one keeps RecoverInterval,
+	// the count restarts once CLOSED.
+	RecoverBackoff     float64
+	MaxRecoverInterval time.Duration
+	RecoverJitter      Jitter
+
+	// Once recovered, admits a growing fraction of the calls over this
+	// interval before CLOSED, reopening if the Policy trips on them. Zero
+	// goes straight to CLOSED.
+	RampUpInterval time.Duration
+	RampUp         Ramp
+
+	// Rejects calls locally with probability max(0, (requests -
+	// K*accepts)/(requests+1)) over the rolling window instead of tripping,
+	// zero disables it. The SRE book suggests a K of 2.
+	ThrottleK float64
+
+	// Limits the number of concurrent trial calls admitted by Allow while
+	// HALFOPEN, zero means unlimited.
+	MaxHalfOpenRequests uint32
+
+	// Calls lasting at least this long are counted as slow, zero disables
+	// slow call counting.
+	SlowCallThreshold time.Duration
+
+	// Called on every state change, see Subscribe.
+	OnStateChange func(Transition)
 }
 
 type Breaker struct {
@@ -61,99 +213,186 @@ type Breaker struct {
 
 	buckets        []bucket
 	recoveryBucket bucket
-	state          uint8
-	c              chan uint8
+	cur            atomic.Pointer[epoch]
+	c              chan Outcome
+	forward        sync.Once
 	ctx            context.Context
 	cancelFunc     context.CancelFunc
+	events         notifier
+
+	// Guarded by the breaker lock.
+	nextFlush  time.Time
+	nextRotate time.Time
+	nextUpdate time.Time
+	recoverAt  time.Time
+	openFor    time.Duration
+	reopens    uint32
+	rampUntil  time.Time
+
+	// Guarded by the scheduler lock.
+	sched     *scheduler
+	scheduled bool
+	running   bool
+	index     int
+	due       time.Time
+	pending   time.Time
 
 	MetricsOptions
 
-	isOpen   func([]bucket) uint8
-	isClosed func(bucket) uint8
+	policy  Policy
+	streaks StreakPolicy
+
+	// Guarded by the breaker lock.
+	totals Totals
+
+	throttle atomic.Uint64
 }
 
-func NewBreaker(metricsOptions MetricsOptions, _isOpen func([]bucket) uint8, _isClosed func(bucket) uint8) *Breaker {
+func newBreaker(metricsOptions MetricsOptions, policy Policy) *Breaker {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
 	b := &Breaker{
 		buckets:    make([]bucket, metricsOptions.MetricsRollingCount),
-		state:      CLOSED,
-		c:          make(chan uint8),
+		c:          make(chan Outcome),
 		ctx:        ctx,
 		cancelFunc: cancelFunc,
+		sched:      defaultScheduler,
+		index:      -1,
 
 		MetricsOptions: metricsOptions,
 	}
 
-	b.isOpen = _isOpen
-	b.isClosed = _isClosed
+	b.cur.Store(newEpoch(CLOSED))
+	b.policy = policy
+	b.streaks, _ = policy.(StreakPolicy)
 
-	go b.Start()
 	return b
 }
 
-func NewBreakerWithDefault(metricsOptions MetricsOptions) *Breaker {
-	ctx, cancelFunc := context.WithCancel(context.Background())
-
-	b := &Breaker{
-		buckets:    make([]bucket, metricsOptions.MetricsRollingCount),
-		state:      CLOSED,
-		c:          make(chan uint8),
-		ctx:        ctx,
-		cancelFunc: cancelFunc,
-
-		MetricsOptions: metricsOptions,
-	}
-
-	b.isOpen = IsOpen
-	b.isClosed = IsClosed
+func NewBreaker(metricsOptions MetricsOptions, _isOpen func(Window) Decision, _isClosed func(Counts) Decision) *Breaker {
+	return NewBreakerWithPolicy(metricsOptions, PolicyFuncs{Trip: _isOpen, Recover: _isClosed})
+}
 
-	go b.Start()
+func NewBreakerWithPolicy(metricsOptions MetricsOptions, policy Policy) *Breaker {
+	b := newBreaker(metricsOptions, policy)
 
+	b.Start()
 	return b
 }
 
-func (b *Breaker) recovery(tick *time.Ticker) {
-	for {
-		select {
-		case <-b.ctx.Done():
-			tick.Stop()
-			return
-		case <-tick.C:
-			b.changeState(HALFOPEN)
-			tick.Stop()
-			return
-		}
+func NewBreakerWithDefault(metricsOptions MetricsOptions) *Breaker {
+	return NewBreakerWithPolicy(metricsOptions, DefaultPolicy)
+}
+
+func (b *Breaker) current() *epoch {
+	e := b.cur.Load()
+	if e == nil {
+		b.cur.CompareAndSwap(nil, newEpoch(CLOSED))
+		e = b.cur.Load()
 	}
+
+	return e
 }
 
 func (b *Breaker) setStateClosed() {
-	b.state = CLOSED
-	for i := 0; i < int(b.MetricsRollingCount); i++ {
+	b.cur.Store(newEpoch(CLOSED))
+	b.recoverAt = time.Time{}
+	b.openFor = 0
+	b.reopens = 0
+	b.rampUntil = time.Time{}
+	for i := range b.buckets {
 		b.buckets[i].reset()
 	}
+	b.throttle.Store(0)
 }
 
 func (b *Breaker) setStateOpen() {
-	b.state = OPEN
+	b.cur.Store(newEpoch(OPEN))
 
-	for i := 0; i < int(b.MetricsRollingCount); i++ {
+	for i := range b.buckets {
 		b.buckets[i].reset()
 	}
 
-	go b.recovery(time.NewTicker(b.RecoverInterval))
+	b.openFor = b.openDuration(b.reopens, b.openFor)
+	b.rampUntil = time.Time{}
+	b.recoverAt = time.Now().Add(b.openFor)
+	b.sched.reschedule(b, b.recoverAt)
 }
 
 func (b *Breaker) setStateHalfOpen() {
-	b.state = HALFOPEN
+	b.cur.Store(newEpoch(HALFOPEN))
+	b.recoverAt = time.Time{}
+	b.rampUntil = time.Time{}
 	b.recoveryBucket.reset()
 }
 
-func (b *Breaker) changeState(state uint) {
+func (b *Breaker) setStateRampUp() {
+	e := newEpoch(RAMPUP)
+	b.cur.Store(e)
+	b.recoveryBucket.reset()
+
+	b.rampUntil = e.since.Add(b.RampUpInterval)
+	b.sched.reschedule(b, b.rampUntil)
+}
+
+// recoveryDue returns when the breaker moves to HALFOPEN, zero unless OPEN.
+func (b *Breaker) recoveryDue() time.Time {
 	b.Lock()
 	defer b.Unlock()
 
-	switch b.state {
+	return b.recoverAt
+}
+
+func (b *Breaker) countsOf(state State) []Counts {
+	switch state {
+	case CLOSED, DISABLED:
+		counts := make([]Counts, len(b.buckets))
+		for i := range b.buckets {
+			counts[i] = b.buckets[i].counts()
+		}
+		return counts
+	case HALFOPEN, RAMPUP:
+		return []Counts{b.recoveryBucket.counts()}
+	}
+
+	return nil
+}
+
+func (b *Breaker) changeState(state State) {
+	b.Lock()
+	t, ok := b.transit(state)
+	b.Unlock()
+
+	if ok {
+		b.publish(t)
+	}
+}
+
+// changeStateFrom changes the state only if no other change happened since
+// the epoch e started.
+func (b *Breaker) changeStateFrom(e *epoch, state State) {
+	b.Lock()
+	if b.current() != e {
+		b.Unlock()
+		return
+	}
+	t, ok := b.transit(state)
+	b.Unlock()
+
+	if ok {
+		b.publish(t)
+	}
+}
+
+// transit must be called with the lock held, the returned transition is
+// published by the caller once the lock is released.
+func (b *Breaker) transit(state State) (Transition, bool) {
+	b.flush()
+
+	from := b.State()
+	counts := b.countsOf(from)
+
+	switch from {
 	case CLOSED:
 		if state == OPEN {
 			b.setStateOpen()
@@ -164,127 +403,238 @@ func (b *Breaker) changeState(state uint) {
 		}
 	case HALFOPEN:
 		if state == OPEN {
+			b.reopens++
+			b.setStateOpen()
+		}
+		if state == CLOSED && b.RampUpInterval > 0 {
+			b.setStateRampUp()
+		} else if state == CLOSED {
+			b.setStateClosed()
+		}
+	case RAMPUP:
+		if state == OPEN {
+			b.reopens++
 			b.setStateOpen()
 		}
 		if state == CLOSED {
 			b.setStateClosed()
 		}
 	}
+
+	to := b.State()
+	if from == to {
+		return Transition{}, false
+	}
+
+	b.totals.change(from, to)
+
+	return Transition{
+		Name:   b.Name,
+		From:   from,
+		To:     to,
+		At:     time.Now(),
+		Reason: reasonOf(from, to),
+		Counts: counts,
+	}, true
 }
 
-func (b *Breaker) receiving(tick *time.Ticker) {
-	var succeed, failed, timeout, reject uint32
-	var rsucceed, rfailed, rtimeout, rreject uint32
+// flush moves the outcomes recorded so far into the newest bucket, or into
+// the recovery bucket while HALFOPEN or RAMPUP. Only the calls rejected by
+// the breaker are kept while OPEN, and none while FORCEDCLOSED. It must be
+// called with the lock held.
+func (b *Breaker) flush() {
+	var discard bucket
 
-	for {
-		select {
-		case <-b.ctx.Done():
-			tick.Stop()
-			return
-		case <-tick.C:
-			b.buckets[b.MetricsRollingCount-1].succeed += succeed
-			b.buckets[b.MetricsRollingCount-1].failed += failed
-			b.buckets[b.MetricsRollingCount-1].timeout += timeout
-			b.buckets[b.MetricsRollingCount-1].reject += reject
-			succeed, failed, timeout, reject = 0, 0, 0, 0
-			b.recoveryBucket.succeed += rsucceed
-			b.recoveryBucket.failed += rfailed
-			b.recoveryBucket.timeout += rtimeout
-			b.recoveryBucket.reject += rreject
-			rsucceed, rfailed, rtimeout, rreject = 0, 0, 0, 0
-		case state := <-b.c:
-			switch b.state {
-			case OPEN:
-				continue
-			case HALFOPEN:
-				switch state {
-				case SUCCEED:
-					rsucceed++
-				case FAILED:
-					rfailed++
-				case TIMEOUT:
-					rtimeout++
-				case REJECT:
-					rreject++
-				}
-			case CLOSED:
-				switch state {
-				case SUCCEED:
-					succeed++
-				case FAILED:
-					failed++
-				case TIMEOUT:
-					timeout++
-				case REJECT:
-					reject++
-				}
-			}
+	e := b.current()
+	dst := &discard
+
+	switch e.state {
+	case CLOSED, DISABLED:
+		if n := len(b.buckets); n > 0 {
+			dst = &b.buckets[n-1]
+		}
+	case HALFOPEN, RAMPUP:
+		dst = &b.recoveryBucket
+	}
+
+	drained := e.drainInto(dst)
+	b.totals.add(drained)
+
+	if n := len(b.buckets); (e.state == OPEN || e.state == FORCEDOPEN) && n > 0 {
+		b.buckets[n-1].short += drained.ShortCircuited
+	}
+
+	if e.state == CLOSED {
+		b.updateThrottle()
+	}
+}
+
+// rotate must be called with the lock held.
+func (b *Breaker) rotate() {
+	b.flush()
+
+	// The oldest bucket is reused as the newest one, so that no histogram
+	// is shared between two buckets.
+	if n := len(b.buckets); n > 0 {
+		oldest := b.buckets[0]
+		copy(b.buckets, b.buckets[1:])
+		b.buckets[n-1] = oldest
+		b.buckets[n-1].reset()
+	}
+
+	if b.State() == CLOSED {
+		b.updateThrottle()
+	}
+}
+
+// evaluate must be called with the lock held.
+func (b *Breaker) evaluate() (Transition, bool) {
+	switch b.State() {
+	case OPEN:
+	case HALFOPEN:
+		switch b.policy.ShouldRecover(b.recoveryBucket.counts()) {
+		case TRUE:
+			return b.transit(CLOSED)
+		case FALSE:
+			return b.transit(OPEN)
+		case HOLD:
+		}
+	case CLOSED, RAMPUP:
+		if b.State() == CLOSED && b.ThrottleK > 0 {
+			break
+		}
+		if b.policy.ShouldTrip(Window{buckets: b.countsOf(b.State())}) == TRUE {
+			return b.transit(OPEN)
 		}
 	}
+
+	return Transition{}, false
 }
 
-func (b *Breaker) updateState(tick *time.Ticker) {
+func after(now time.Time, d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+
+	return now.Add(d)
+}
+
+func reached(now, t time.Time) bool {
+	return t.IsZero() == false && now.Before(t) == false
+}
+
+func earliest(times ...time.Time) time.Time {
+	var res time.Time
+
+	for _, t := range times {
+		if t.IsZero() == false && (res.IsZero() || t.Before(res)) {
+			res = t
+		}
+	}
+
+	return res
+}
+
+// run is called by the scheduler, it does the work that is due at now and
+// returns when it should be called again.
+func (b *Breaker) run(now time.Time) time.Time {
+	var transitions []Transition
+
+	b.Lock()
+
+	if reached(now, b.nextFlush) {
+		b.flush()
+		b.nextFlush = after(now, b.ReceiveInterval)
+	}
+
+	if reached(now, b.nextRotate) {
+		b.rotate()
+		b.nextRotate = after(now, b.MetricsInterval)
+	}
+
+	if reached(now, b.recoverAt) {
+		if t, ok := b.transit(HALFOPEN); ok {
+			transitions = append(transitions, t)
+		}
+	}
+
+	if reached(now, b.rampUntil) {
+		if t, ok := b.transit(CLOSED); ok {
+			transitions = append(transitions, t)
+		}
+	}
+
+	if reached(now, b.nextUpdate) {
+		if t, ok := b.evaluate(); ok {
+			transitions = append(transitions, t)
+		}
+		b.nextUpdate = after(now, b.UpdateStateInterval)
+	}
+
+	next := earliest(b.nextFlush, b.nextRotate, b.nextUpdate, b.recoverAt, b.rampUntil)
+
+	b.Unlock()
+
+	for _, t := range transitions {
+		b.publish(t)
+	}
+
+	return next
+}
+
+// forwarding records the outcomes sent to Chan.
+func (b *Breaker) forwarding() {
 	for {
 		select {
 		case <-b.ctx.Done():
-			tick.Stop()
 			return
-		case <-tick.C:
-			// fmt.Println(b.buckets, b.recoveryBucket, b.state)
-			state := b.state
-			switch state {
-			case OPEN:
-			case HALFOPEN:
-				res := b.isClosed(b.recoveryBucket)
-				switch res {
-				case TRUE:
-					b.changeState(CLOSED)
-				case FALSE:
-					b.changeState(OPEN)
-				case HOLD:
-				}
-			case CLOSED:
-				res := b.isOpen(b.buckets)
-				if res == TRUE {
-					b.changeState(OPEN)
-				}
+		case outcome := <-b.c:
+			e := b.current()
+			if e.state == HALFOPEN && b.MaxHalfOpenRequests > 0 {
+				continue
 			}
+			b.record(e, outcome, 0)
 		}
 	}
 }
 
-func (b *Breaker) Chan() chan<- uint8 {
-	return (chan<- uint8)(b.c)
+// Chan is kept for callers reporting outcomes by hand, Allow and Do record
+// them without going through a channel.
+func (b *Breaker) Chan() chan<- Outcome {
+	b.forward.Do(func() {
+		go b.forwarding()
+	})
+
+	return (chan<- Outcome)(b.c)
 }
 
 func (b *Breaker) Active() bool {
-	if b.state == OPEN {
+	e := b.current()
+
+	switch e.state {
+	case OPEN, FORCEDOPEN:
 		return true
+	case HALFOPEN:
+		return b.MaxHalfOpenRequests > 0 && atomic.LoadInt32(&e.probes) >= int32(b.MaxHalfOpenRequests)
 	}
 
 	return false
 }
 
 func (b *Breaker) Start() {
-	go b.receiving(time.NewTicker(b.ReceiveInterval))
-	go b.updateState(time.NewTicker(b.UpdateStateInterval))
+	now := time.Now()
 
-	tick := time.NewTicker(b.MetricsInterval)
+	b.Lock()
+	b.nextFlush = after(now, b.ReceiveInterval)
+	b.nextRotate = after(now, b.MetricsInterval)
+	b.nextUpdate = after(now, b.UpdateStateInterval)
+	due := earliest(b.nextFlush, b.nextRotate, b.nextUpdate, b.recoverAt, b.rampUntil)
+	b.Unlock()
 
-	for {
-		select {
-		case <-b.ctx.Done():
-			tick.Stop()
-			return
-		case <-tick.C:
-			for i := 0; i < int(b.MetricsRollingCount)-1; i++ {
-				b.buckets[i] = b.buckets[i+1]
-			}
-			b.buckets[b.MetricsRollingCount-1].reset()
-		}
-	}
+	b.sched.register(b, due)
 }
 
 func (b *Breaker) Stop() {
+	b.sched.unregister(b)
 	b.cancelFunc()
 }
@@ -0,0 +1,82 @@
+package breaker
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func BenchmarkBreakerMethodDo(b *testing.B) {
+	metricsOptions := MetricsOptions{
+		MetricsRollingCount: 10,
+		MetricsInterval:     1 * time.Second,
+		ReceiveInterval:     100 * time.Millisecond,
+		UpdateStateInterval: 100 * time.Millisecond,
+		RecoverInterval:     5 * time.Second,
+	}
+
+	br := NewBreakerWithDefault(metricsOptions)
+	defer br.Stop()
+
+	fn := func(context.Context) error { return nil }
+
+	b.RunParallel(func(pb *testing.PB) {
+		ctx := context.Background()
+		for pb.Next() {
+			br.Do(ctx, fn)
+		}
+	})
+}
+
+func BenchmarkBreakerMethodChan(b *testing.B) {
+	metricsOptions := MetricsOptions{
+		MetricsRollingCount: 10,
+		MetricsInterval:     1 * time.Second,
+		ReceiveInterval:     100 * time.Millisecond,
+		UpdateStateInterval: 100 * time.Millisecond,
+		RecoverInterval:     5 * time.Second,
+	}
+
+	br := NewBreakerWithDefault(metricsOptions)
+	defer br.Stop()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			br.Chan() <- SUCCEED
+		}
+	})
+}
+
+func BenchmarkNewBreaker(b *testing.B) {
+	metricsOptions := MetricsOptions{
+		MetricsRollingCount: 10,
+		MetricsInterval:     1 * time.Second,
+		ReceiveInterval:     100 * time.Millisecond,
+		UpdateStateInterval: 100 * time.Millisecond,
+		RecoverInterval:     5 * time.Second,
+	}
+
+	var before, after runtime.MemStats
+
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	goroutines := runtime.NumGoroutine()
+
+	breakers := make([]*Breaker, b.N)
+	for i := range breakers {
+		breakers[i] = NewBreakerWithDefault(metricsOptions)
+	}
+
+	b.StopTimer()
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	b.ReportMetric(float64(runtime.NumGoroutine()-goroutines)/float64(b.N), "goroutines/breaker")
+	b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/float64(b.N), "heap-bytes/breaker")
+
+	for _, br := range breakers {
+		br.Stop()
+	}
+}
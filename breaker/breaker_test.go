@@ -3,6 +3,7 @@ package breaker
 import (
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func TestBucketMethodReset(t *testing.T) {
@@ -20,6 +21,32 @@ func TestBucketMethodReset(t *testing.T) {
 	}
 }
 
+func TestShardShouldFillCacheLines(t *testing.T) {
+	if size := unsafe.Sizeof(shard{}); size%64 != 0 {
+		t.Fatalf("shard should be padded to a multiple of 64 bytes, got: %d", size)
+	}
+}
+
+func TestEpochMethodAddAndDrain(t *testing.T) {
+	e := newEpoch(CLOSED)
+
+	e.add(SUCCEED)
+	e.add(SUCCEED)
+	e.add(FAILED)
+	e.add(TIMEOUT)
+	e.add(REJECT)
+	e.add(REJECT + 1)
+
+	res := e.drain()
+	if res.succeed != 2 || res.failed != 1 || res.timeout != 1 || res.reject != 1 {
+		t.Fatalf("drain error. succeed: %d failed: %d timeout: %d reject: %d", res.succeed, res.failed, res.timeout, res.reject)
+	}
+
+	if res = e.drain(); res.all() != 0 {
+		t.Fatalf("drain should reset counters, got: %d", res.all())
+	}
+}
+
 func TestBreaker(t *testing.T) {
 	metricsOptions := MetricsOptions{
 		MetricsRollingCount: 5,
@@ -29,7 +56,7 @@ func TestBreaker(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	NewBreaker(metricsOptions, IsOpen, IsClosed)
+	NewBreaker(metricsOptions, IsOpen, IsClosed).Stop()
 }
 
 func TestBreakerMethodchangeStateWhenClosed2Open(t *testing.T) {
@@ -41,15 +68,16 @@ func TestBreakerMethodchangeStateWhenClosed2Open(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreakerWithDefault(metricsOptions)
+	b := newBreaker(metricsOptions, DefaultPolicy)
+	defer b.Stop()
 
-	b.state = CLOSED
 	b.buckets[0].succeed = 1
 	b.buckets[4].failed = 1
+	b.current().add(FAILED)
 	b.changeState(OPEN)
 
-	if b.state != OPEN {
-		t.Fatalf("state should be open, got: %v", b.state)
+	if b.State() != OPEN {
+		t.Fatalf("state should be open, got: %v", b.State())
 	}
 
 	for i := 0; i < int(b.MetricsRollingCount); i++ {
@@ -58,6 +86,9 @@ func TestBreakerMethodchangeStateWhenClosed2Open(t *testing.T) {
 		}
 	}
 
+	if res := b.current().drain(); res.all() != 0 {
+		t.Fatal("set state open should start an empty epoch")
+	}
 }
 
 func TestBreakerMethodchangeStateWhenOpen2HalfOpen(t *testing.T) {
@@ -69,13 +100,14 @@ func TestBreakerMethodchangeStateWhenOpen2HalfOpen(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreaker(metricsOptions, IsOpen, IsClosed)
+	b := newBreaker(metricsOptions, DefaultPolicy)
+	defer b.Stop()
 
-	b.state = OPEN
+	b.cur.Store(newEpoch(OPEN))
 	b.changeState(HALFOPEN)
 
-	if b.state != HALFOPEN {
-		t.Fatalf("state should be halfopen, got: %v", b.state)
+	if b.State() != HALFOPEN {
+		t.Fatalf("state should be halfopen, got: %v", b.State())
 	}
 }
 
@@ -88,13 +120,14 @@ func TestBreakerMethodchangeStateWhenHalfOpen2Open(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreaker(metricsOptions, IsOpen, IsClosed)
+	b := newBreaker(metricsOptions, DefaultPolicy)
+	defer b.Stop()
 
-	b.state = HALFOPEN
+	b.cur.Store(newEpoch(HALFOPEN))
 	b.changeState(OPEN)
 
-	if b.state != OPEN {
-		t.Fatalf("state should be halfopen, got: %v", b.state)
+	if b.State() != OPEN {
+		t.Fatalf("state should be halfopen, got: %v", b.State())
 	}
 }
 
@@ -107,17 +140,18 @@ func TestBreakerMethodchangeStateWhenHalfOpen2Closed(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreaker(metricsOptions, IsOpen, IsClosed)
+	b := newBreaker(metricsOptions, DefaultPolicy)
+	defer b.Stop()
 
-	b.state = HALFOPEN
+	b.cur.Store(newEpoch(HALFOPEN))
 	b.changeState(CLOSED)
 
-	if b.state != CLOSED {
-		t.Fatalf("state should be halfopen, got: %v", b.state)
+	if b.State() != CLOSED {
+		t.Fatalf("state should be halfopen, got: %v", b.State())
 	}
 }
 
-func TestBreakerMethodReceiving(t *testing.T) {
+func TestBreakerMethodRunFlush(t *testing.T) {
 	metricsOptions := MetricsOptions{
 		MetricsRollingCount: 5,
 		MetricsInterval:     1 * time.Second,
@@ -126,64 +160,53 @@ func TestBreakerMethodReceiving(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreaker(metricsOptions, IsOpen, IsClosed)
-
-	tick := time.NewTicker(100 * time.Second)
-	c := make(chan time.Time)
-	tick.C = c
-	go b.receiving(tick)
+	b := newBreaker(metricsOptions, DefaultPolicy)
+	now := time.Now()
+	b.nextFlush = now
 
-	b.c <- SUCCEED
-	b.c <- FAILED
-	b.c <- TIMEOUT
-	b.c <- REJECT
+	b.current().add(SUCCEED)
+	b.current().add(FAILED)
+	b.current().add(TIMEOUT)
+	b.current().add(REJECT)
 
-	c <- time.Now()
+	b.run(now)
 
-	if b.buckets[4].succeed != 1 && b.buckets[4].failed != 1 && b.buckets[4].timeout != 1 && b.buckets[4].reject != 1 {
+	if b.buckets[4].succeed != 1 || b.buckets[4].failed != 1 || b.buckets[4].timeout != 1 || b.buckets[4].reject != 1 {
 		t.Fatalf("breaker method error. succeed: %d failed: %d timeout: %d reject: %d", b.buckets[4].succeed, b.buckets[4].failed, b.buckets[4].timeout, b.buckets[4].reject)
 	}
-
-	b.Stop()
-	select {
-	case b.c <- SUCCEED:
-		t.Fatalf("b.c should be blocked")
-	default:
+	if b.nextFlush != now.Add(b.ReceiveInterval) {
+		t.Fatalf("next flush should be %v, got: %v", now.Add(b.ReceiveInterval), b.nextFlush)
 	}
 
-	metricsOptions = MetricsOptions{
-		MetricsRollingCount: 5,
-		MetricsInterval:     1 * time.Second,
-		ReceiveInterval:     1 * time.Second,
-		UpdateStateInterval: 1 * time.Second,
-		RecoverInterval:     5 * time.Second,
-	}
+	b.cur.Store(newEpoch(HALFOPEN))
 
-	b = NewBreaker(metricsOptions, IsOpen, IsClosed)
+	b.current().add(SUCCEED)
+	b.current().add(FAILED)
+	b.current().add(TIMEOUT)
+	b.current().add(REJECT)
 
-	tick = time.NewTicker(100 * time.Second)
-	c = make(chan time.Time)
-	tick.C = c
-	b.state = HALFOPEN
-	go b.receiving(tick)
+	b.run(now)
 
-	b.c <- SUCCEED
-	b.c <- FAILED
-	b.c <- TIMEOUT
-	b.c <- REJECT
+	if b.recoveryBucket.all() != 0 {
+		t.Fatalf("flush should wait for the receive interval, got: %d", b.recoveryBucket.all())
+	}
 
-	c <- time.Now()
+	b.run(now.Add(b.ReceiveInterval))
 
-	if b.recoveryBucket.succeed != 1 && b.recoveryBucket.failed != 1 && b.recoveryBucket.timeout != 1 && b.recoveryBucket.reject != 1 {
+	if b.recoveryBucket.succeed != 1 || b.recoveryBucket.failed != 1 || b.recoveryBucket.timeout != 1 || b.recoveryBucket.reject != 1 {
 		t.Fatalf("breaker method error. succeed: %d failed: %d timeout: %d reject: %d", b.recoveryBucket.succeed, b.recoveryBucket.failed, b.recoveryBucket.timeout, b.recoveryBucket.reject)
 	}
 
-	b.state = OPEN
-	b.c <- SUCCEED
+	b.cur.Store(newEpoch(OPEN))
+	b.current().add(SUCCEED)
+	b.run(now.Add(2 * b.ReceiveInterval))
 
+	if b.recoveryBucket.succeed != 1 || b.buckets[4].succeed != 1 {
+		t.Fatalf("outcomes should be dropped when open. recovery: %d bucket: %d", b.recoveryBucket.succeed, b.buckets[4].succeed)
+	}
 }
 
-func TestBreakerMethodUpdateState(t *testing.T) {
+func TestBreakerMethodRotate(t *testing.T) {
 	metricsOptions := MetricsOptions{
 		MetricsRollingCount: 5,
 		MetricsInterval:     1 * time.Second,
@@ -192,89 +215,31 @@ func TestBreakerMethodUpdateState(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreaker(metricsOptions, func([]bucket) uint8 { return TRUE }, IsClosed)
-
-	tick := time.NewTicker(100 * time.Second)
-	c := make(chan time.Time)
-	tick.C = c
+	b := newBreaker(metricsOptions, DefaultPolicy)
+	defer b.Stop()
 
-	go b.updateState(tick)
+	b.buckets[4].succeed = 1
+	b.current().add(FAILED)
+	b.current().addSlow()
+	b.rotate()
 
-	c <- time.Now()
-
-	if b.state != OPEN {
-		t.Fatalf("state should be OPEN, got: %v", b.state)
+	if b.buckets[3].succeed != 1 || b.buckets[3].failed != 1 || b.buckets[3].slow != 1 {
+		t.Fatalf("rotate should shift the flushed bucket. succeed: %d failed: %d", b.buckets[3].succeed, b.buckets[3].failed)
 	}
-
-	metricsOptions = MetricsOptions{
-		MetricsRollingCount: 5,
-		MetricsInterval:     1 * time.Second,
-		ReceiveInterval:     1 * time.Second,
-		UpdateStateInterval: 1 * time.Second,
-		RecoverInterval:     5 * time.Second,
+	if b.buckets[4].all() != 0 {
+		t.Fatalf("rotate should reset the newest bucket, got: %d", b.buckets[4].all())
 	}
 
-	b = NewBreaker(metricsOptions, func([]bucket) uint8 { return HOLD }, IsClosed)
-
-	tick = time.NewTicker(100 * time.Second)
-	c = make(chan time.Time)
-	tick.C = c
-
-	go b.updateState(tick)
-
-	c <- time.Now()
-
-	if b.state != CLOSED {
-		t.Fatalf("state should be CLOSED, got: %v", b.state)
-	}
-
-	metricsOptions = MetricsOptions{
-		MetricsRollingCount: 5,
-		MetricsInterval:     1 * time.Second,
-		ReceiveInterval:     1 * time.Second,
-		UpdateStateInterval: 1 * time.Second,
-		RecoverInterval:     5 * time.Second,
-	}
-
-	b = NewBreaker(metricsOptions, IsOpen, func(bucket) uint8 { return HOLD })
-	b.state = HALFOPEN
-
-	tick = time.NewTicker(100 * time.Second)
-	c = make(chan time.Time)
-	tick.C = c
-
-	go b.updateState(tick)
-
-	c <- time.Now()
-
-	if b.state != HALFOPEN {
-		t.Fatalf("state should be HALFOPEN, got: %v", b.state)
-	}
-
-	metricsOptions = MetricsOptions{
-		MetricsRollingCount: 5,
-		MetricsInterval:     1 * time.Second,
-		ReceiveInterval:     1 * time.Second,
-		UpdateStateInterval: 1 * time.Second,
-		RecoverInterval:     5 * time.Second,
-	}
-
-	b = NewBreaker(metricsOptions, IsOpen, func(bucket) uint8 { return TRUE })
-	b.state = HALFOPEN
-
-	tick = time.NewTicker(100 * time.Second)
-	c = make(chan time.Time)
-	tick.C = c
-
-	go b.updateState(tick)
-
-	c <- time.Now()
+	b.buckets[0].failed = 1
+	b.rotate()
 
-	if b.state != CLOSED {
-		t.Fatalf("state should be CLOSED, got: %v", b.state)
+	if b.buckets[0].failed != 0 || b.buckets[2].succeed != 1 {
+		t.Fatalf("rotate should drop the oldest bucket")
 	}
+}
 
-	metricsOptions = MetricsOptions{
+func TestBreakerMethodRunUpdateState(t *testing.T) {
+	metricsOptions := MetricsOptions{
 		MetricsRollingCount: 5,
 		MetricsInterval:     1 * time.Second,
 		ReceiveInterval:     1 * time.Second,
@@ -282,52 +247,41 @@ func TestBreakerMethodUpdateState(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b = NewBreaker(metricsOptions, IsOpen, func(bucket) uint8 { return FALSE })
-	b.state = HALFOPEN
-
-	tick = time.NewTicker(100 * time.Second)
-	c = make(chan time.Time)
-	tick.C = c
-
-	go b.updateState(tick)
-
-	c <- time.Now()
-
-	if b.state != OPEN {
-		t.Fatalf("state should be OPEN, got: %v", b.state)
+	cases := []struct {
+		isOpen   func(Window) Decision
+		isClosed func(Counts) Decision
+		from, to State
+	}{
+		{func(Window) Decision { return TRUE }, IsClosed, CLOSED, OPEN},
+		{func(Window) Decision { return HOLD }, IsClosed, CLOSED, CLOSED},
+		{IsOpen, func(Counts) Decision { return HOLD }, HALFOPEN, HALFOPEN},
+		{IsOpen, func(Counts) Decision { return TRUE }, HALFOPEN, CLOSED},
+		{IsOpen, func(Counts) Decision { return FALSE }, HALFOPEN, OPEN},
+		{IsOpen, func(Counts) Decision { return FALSE }, OPEN, OPEN},
 	}
 
-	metricsOptions = MetricsOptions{
-		MetricsRollingCount: 5,
-		MetricsInterval:     1 * time.Second,
-		ReceiveInterval:     1 * time.Second,
-		UpdateStateInterval: 1 * time.Second,
-		RecoverInterval:     5 * time.Second,
-	}
+	for _, cs := range cases {
+		b := newBreaker(metricsOptions, PolicyFuncs{Trip: cs.isOpen, Recover: cs.isClosed})
+		b.cur.Store(newEpoch(cs.from))
 
-	b = NewBreaker(metricsOptions, IsOpen, func(bucket) uint8 { return FALSE })
-	b.state = OPEN
+		now := time.Now()
+		b.nextUpdate = now.Add(time.Millisecond)
 
-	tick = time.NewTicker(100 * time.Second)
-	c = make(chan time.Time)
-	tick.C = c
+		b.run(now)
 
-	go b.updateState(tick)
-
-	c <- time.Now()
-
-	if b.state != OPEN {
-		t.Fatalf("state should be OPEN, got: %v", b.state)
-	}
+		if b.State() != cs.from {
+			t.Fatalf("state should not be updated before the interval, got: %v", b.State())
+		}
 
-	b.Stop()
+		b.run(now.Add(time.Millisecond))
 
-	select {
-	case c <- time.Now():
-		t.Fatalf("channel should be blocked")
-	default:
+		if b.State() != cs.to {
+			t.Fatalf("state should be %v, got: %v", cs.to, b.State())
+		}
+		if b.nextUpdate != now.Add(time.Millisecond+b.UpdateStateInterval) {
+			t.Fatalf("next update should be scheduled, got: %v", b.nextUpdate)
+		}
 	}
-
 }
 
 func TestBreakerMethodChan(t *testing.T) {
@@ -339,18 +293,24 @@ func TestBreakerMethodChan(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreaker(metricsOptions, func([]bucket) uint8 { return TRUE }, IsClosed)
+	b := newBreaker(metricsOptions, PolicyFuncs{Trip: func(Window) Decision { return TRUE }, Recover: IsClosed})
+	defer b.Stop()
+
+	b.Chan() <- FAILED
+	b.Chan() <- SUCCEED
 
-	b.c = make(chan uint8)
+	if res := b.current().drain(); res.failed != 1 {
+		t.Fatalf("failed should be 1, got: %v", res.failed)
+	}
 
-	go func() {
-		b.Chan() <- 1
-	}()
+	b.MaxHalfOpenRequests = 1
+	b.cur.Store(newEpoch(HALFOPEN))
 
-	res := <-b.c
+	b.Chan() <- FAILED
+	b.Chan() <- FAILED
 
-	if res != 1 {
-		t.Fatalf("result should be 1, got: %v", res)
+	if res := b.current().drain(); res.all() != 0 {
+		t.Fatalf("outcomes should be ignored while probes are limited, got: %v", res.all())
 	}
 }
 
@@ -363,28 +323,36 @@ func TestBreakerMethodActive(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreaker(metricsOptions, func([]bucket) uint8 { return TRUE }, IsClosed)
+	b := newBreaker(metricsOptions, PolicyFuncs{Trip: func(Window) Decision { return TRUE }, Recover: IsClosed})
 
-	b.state = CLOSED
+	b.cur.Store(newEpoch(CLOSED))
 	result := b.Active()
 	if result != false {
 		t.Fatalf("result should be false, got: %v", result)
 	}
 
-	b.state = HALFOPEN
+	b.cur.Store(newEpoch(HALFOPEN))
 	result = b.Active()
 	if result != false {
 		t.Fatalf("result should be false, got: %v", result)
 	}
 
-	b.state = OPEN
+	b.cur.Store(newEpoch(OPEN))
+	result = b.Active()
+	if result != true {
+		t.Fatalf("result should be true, got: %v", result)
+	}
+
+	b.MaxHalfOpenRequests = 1
+	b.cur.Store(newEpoch(HALFOPEN))
+	b.current().probes = 1
 	result = b.Active()
 	if result != true {
 		t.Fatalf("result should be true, got: %v", result)
 	}
 }
 
-func TestBreakerMethodRecovery(t *testing.T) {
+func TestBreakerMethodRunRecovery(t *testing.T) {
 	metricsOptions := MetricsOptions{
 		MetricsRollingCount: 5,
 		MetricsInterval:     1 * time.Second,
@@ -393,41 +361,95 @@ func TestBreakerMethodRecovery(t *testing.T) {
 		RecoverInterval:     5 * time.Second,
 	}
 
-	b := NewBreaker(metricsOptions, func([]bucket) uint8 { return TRUE }, IsClosed)
+	b := newBreaker(metricsOptions, PolicyFuncs{Trip: func(Window) Decision { return TRUE }, Recover: IsClosed})
 
-	tick := time.NewTicker(1 * time.Second)
-	c := make(chan time.Time)
-	tick.C = c
-	b.state = OPEN
-	go b.recovery(tick)
-	c <- time.Now()
+	b.changeState(OPEN)
+	recoverAt := b.recoverAt
 
-	if b.state != HALFOPEN {
-		t.Fatalf("state should be HALFOPEN, got: %v", b.state)
+	if recoverAt.IsZero() {
+		t.Fatalf("recovery should be scheduled when open")
 	}
 
-	b.state = OPEN
-	go b.recovery(time.NewTicker(1 * time.Second))
-	b.Stop()
+	b.run(recoverAt.Add(-time.Millisecond))
 
-	if b.state != OPEN {
-		t.Fatalf("state should be OPEN, got: %v", b.state)
+	if b.State() != OPEN {
+		t.Fatalf("state should be OPEN, got: %v", b.State())
 	}
 
+	b.run(recoverAt)
+
+	if b.State() != HALFOPEN {
+		t.Fatalf("state should be HALFOPEN, got: %v", b.State())
+	}
+	if b.recoverAt.IsZero() == false {
+		t.Fatalf("recovery should be cleared when halfopen")
+	}
+}
+
+func TestBreakerMethodRunReturnEarliestDueTime(t *testing.T) {
+	metricsOptions := MetricsOptions{
+		MetricsRollingCount: 5,
+		MetricsInterval:     3 * time.Second,
+		ReceiveInterval:     2 * time.Second,
+		UpdateStateInterval: 4 * time.Second,
+		RecoverInterval:     1 * time.Second,
+	}
+
+	b := newBreaker(metricsOptions, DefaultPolicy)
+	now := time.Now()
+	b.nextFlush = now
+	b.nextRotate = now
+	b.nextUpdate = now
+
+	if next := b.run(now); next != now.Add(b.ReceiveInterval) {
+		t.Fatalf("next should be %v, got: %v", now.Add(b.ReceiveInterval), next)
+	}
+
+	b.changeState(OPEN)
+
+	if next := b.run(now); next != b.recoverAt {
+		t.Fatalf("next should be %v, got: %v", b.recoverAt, next)
+	}
+
+	b = newBreaker(MetricsOptions{}, DefaultPolicy)
+	b.nextFlush = now
+	b.nextRotate = now
+	b.nextUpdate = now
+
+	if next := b.run(now); next.IsZero() == false {
+		t.Fatalf("next should be zero without intervals, got: %v", next)
+	}
 }
 
 func TestBreakerMethodStart(t *testing.T) {
 	metricsOptions := MetricsOptions{
 		MetricsRollingCount: 5,
-		MetricsInterval:     1,
-		ReceiveInterval:     1 * time.Second,
-		UpdateStateInterval: 1 * time.Second,
-		RecoverInterval:     5 * time.Second,
+		MetricsInterval:     1 * time.Millisecond,
+		ReceiveInterval:     1 * time.Millisecond,
+		UpdateStateInterval: 1 * time.Millisecond,
+		RecoverInterval:     1 * time.Millisecond,
 	}
 
-	b := NewBreaker(metricsOptions, func([]bucket) uint8 { return TRUE }, IsClosed)
-	go b.Start()
-	time.Sleep(100 * time.Millisecond)
+	b := newBreaker(metricsOptions, PolicyFuncs{Trip: func(Window) Decision { return TRUE }, Recover: func(Counts) Decision { return TRUE }})
+	b.sched = newScheduler()
+
+	transitions := make(chan Transition, 3)
+	b.Subscribe(func(t Transition) { transitions <- t })
+
+	b.Start()
+
+	for _, to := range []State{OPEN, HALFOPEN, CLOSED} {
+		if res := <-transitions; res.To != to {
+			t.Fatalf("transition should be to %v, got: %v", to, res.To)
+		}
+	}
 
 	b.Stop()
+
+	b.sched.Lock()
+	defer b.sched.Unlock()
+
+	if b.scheduled == true || len(b.sched.breakers) != 0 {
+		t.Fatalf("stopped breaker should be unregistered")
+	}
 }
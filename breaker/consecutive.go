@@ -0,0 +1,111 @@
+package breaker
+
+import (
+	"sync/atomic"
+)
+
+// Streak is the run of consecutive outcomes recorded since the last state
+// change. Rejections neither extend nor break a streak.
+type Streak struct {
+	Successes uint32
+	Failures  uint32
+}
+
+// StreakPolicy is a Policy also consulted after every recorded call, so that
+// it can trip or recover without waiting for the window to be evaluated.
+type StreakPolicy interface {
+	Policy
+	ShouldTripOnStreak(Streak) Decision
+	ShouldRecoverOnStreak(Streak) Decision
+}
+
+func (e *epoch) observe(outcome Outcome) (Streak, bool) {
+	for {
+		old := atomic.LoadUint64(&e.streak)
+		s := Streak{Successes: uint32(old >> 32), Failures: uint32(old)}
+
+		switch outcome {
+		case SUCCEED:
+			s = Streak{Successes: s.Successes + 1}
+		case FAILED, TIMEOUT:
+			s = Streak{Failures: s.Failures + 1}
+		default:
+			return s, false
+		}
+
+		if atomic.CompareAndSwapUint64(&e.streak, old, uint64(s.Successes)<<32|uint64(s.Failures)) {
+			return s, true
+		}
+	}
+}
+
+func (b *Breaker) observe(e *epoch, outcome Outcome) {
+	s, ok := e.observe(outcome)
+	if ok == false {
+		return
+	}
+
+	switch e.state {
+	case CLOSED, RAMPUP:
+		if e.state == CLOSED && b.ThrottleK > 0 {
+			return
+		}
+		if b.streaks.ShouldTripOnStreak(s) == TRUE {
+			b.changeStateFrom(e, OPEN)
+		}
+	case HALFOPEN:
+		switch b.streaks.ShouldRecoverOnStreak(s) {
+		case TRUE:
+			b.changeStateFrom(e, CLOSED)
+		case FALSE:
+			b.changeStateFrom(e, OPEN)
+		case HOLD:
+		}
+	}
+}
+
+type consecutivePolicy struct {
+	failures  uint32
+	successes uint32
+}
+
+// NewConsecutivePolicy trips after failures consecutive failures or
+// timeouts, and recovers after successes consecutive trial calls succeeded.
+// A failed trial call reopens the breaker. Zero counts are raised to 1.
+func NewConsecutivePolicy(failures, successes uint32) Policy {
+	if failures == 0 {
+		failures = 1
+	}
+	if successes == 0 {
+		successes = 1
+	}
+
+	return consecutivePolicy{failures: failures, successes: successes}
+}
+
+func (p consecutivePolicy) ShouldTrip(Window) Decision {
+	return HOLD
+}
+
+func (p consecutivePolicy) ShouldRecover(Counts) Decision {
+	return HOLD
+}
+
+func (p consecutivePolicy) ShouldTripOnStreak(s Streak) Decision {
+	if s.Failures >= p.failures {
+		return TRUE
+	}
+
+	return HOLD
+}
+
+func (p consecutivePolicy) ShouldRecoverOnStreak(s Streak) Decision {
+	switch {
+	case s.Failures > 0:
+		return FALSE
+	case s.Successes >= p.successes:
+		return TRUE
+	}
+
+	return HOLD
+}
@@ -0,0 +1,131 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEpochMethodObserve(t *testing.T) {
+	e := newEpoch(CLOSED)
+
+	cases := []struct {
+		outcome Outcome
+		streak  Streak
+		ok      bool
+	}{
+		{FAILED, Streak{Failures: 1}, true},
+		{TIMEOUT, Streak{Failures: 2}, true},
+		{REJECT, Streak{Failures: 2}, false},
+		{SUCCEED, Streak{Successes: 1}, true},
+		{SUCCEED, Streak{Successes: 2}, true},
+		{FAILED, Streak{Failures: 1}, true},
+	}
+
+	for _, c := range cases {
+		if s, ok := e.observe(c.outcome); s != c.streak || ok != c.ok {
+			t.Fatalf("streak after %s should be %+v, %v, got: %+v, %v", c.outcome, c.streak, c.ok, s, ok)
+		}
+	}
+}
+
+func TestConsecutivePolicy(t *testing.T) {
+	p := NewConsecutivePolicy(3, 2).(StreakPolicy)
+
+	if p.ShouldTrip(NewWindow(Counts{Failed: 100})) != HOLD || p.ShouldRecover(Counts{Succeeded: 100}) != HOLD {
+		t.Fatalf("consecutive policy should ignore the window")
+	}
+
+	if p.ShouldTripOnStreak(Streak{Failures: 2}) != HOLD || p.ShouldTripOnStreak(Streak{Failures: 3}) != TRUE {
+		t.Fatalf("consecutive policy should trip after 3 failures")
+	}
+
+	if p.ShouldRecoverOnStreak(Streak{Successes: 1}) != HOLD || p.ShouldRecoverOnStreak(Streak{Successes: 2}) != TRUE {
+		t.Fatalf("consecutive policy should recover after 2 successes")
+	}
+
+	if p.ShouldRecoverOnStreak(Streak{Failures: 1}) != FALSE {
+		t.Fatalf("consecutive policy should reopen on a failed trial call")
+	}
+}
+
+func TestBreakerWithConsecutivePolicy(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 5, RecoverInterval: time.Hour}, NewConsecutivePolicy(3, 2))
+	defer b.Stop()
+
+	e := errors.New("test")
+	fail := func(context.Context) error { return e }
+	succeed := func(context.Context) error { return nil }
+
+	b.Do(context.Background(), fail)
+	b.Do(context.Background(), fail)
+	b.Do(context.Background(), succeed)
+	b.Do(context.Background(), fail)
+	b.Do(context.Background(), fail)
+
+	if b.State() != CLOSED {
+		t.Fatalf("state should be CLOSED when the streak is broken, got: %s", b.State())
+	}
+
+	b.Do(context.Background(), fail)
+
+	if b.State() != OPEN {
+		t.Fatalf("state should be OPEN after 3 consecutive failures, got: %s", b.State())
+	}
+
+	b.changeState(HALFOPEN)
+	b.Do(context.Background(), succeed)
+
+	if b.State() != HALFOPEN {
+		t.Fatalf("state should be HALFOPEN after 1 success, got: %s", b.State())
+	}
+
+	b.Do(context.Background(), succeed)
+
+	if b.State() != CLOSED {
+		t.Fatalf("state should be CLOSED after 2 successes, got: %s", b.State())
+	}
+
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.Do(context.Background(), fail)
+
+	if b.State() != OPEN {
+		t.Fatalf("state should be OPEN after a failed trial call, got: %s", b.State())
+	}
+}
+
+func TestBreakerWithConsecutivePolicyShouldIgnoreStaleCalls(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 5, RecoverInterval: time.Hour}, NewConsecutivePolicy(1, 1))
+	defer b.Stop()
+
+	done, _ := b.Allow()
+
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.changeState(CLOSED)
+
+	done(FAILED)
+
+	if b.State() != CLOSED {
+		t.Fatalf("stale call should not trip the breaker, got: %s", b.State())
+	}
+}
+
+func TestNewConsecutivePolicyShouldRaiseZeroCounts(t *testing.T) {
+	p := NewConsecutivePolicy(0, 0).(StreakPolicy)
+
+	if res := p.ShouldTripOnStreak(Streak{Successes: 1}); res != HOLD {
+		t.Fatalf("policy should not trip on a success streak, got: %s", res)
+	}
+	if res := p.ShouldTripOnStreak(Streak{Failures: 1}); res != TRUE {
+		t.Fatalf("policy should trip on the first failure, got: %s", res)
+	}
+	if res := p.ShouldRecoverOnStreak(Streak{}); res != HOLD {
+		t.Fatalf("policy should not recover without trial calls, got: %s", res)
+	}
+	if res := p.ShouldRecoverOnStreak(Streak{Successes: 1}); res != TRUE {
+		t.Fatalf("policy should recover on the first success, got: %s", res)
+	}
+}
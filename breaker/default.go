@@ -1,13 +1,15 @@
 package breaker
 
-func IsOpen(buckets []bucket) uint8 {
+var DefaultPolicy Policy = PolicyFuncs{Trip: IsOpen, Recover: IsClosed}
+
+func IsOpen(window Window) Decision {
 	var succeed, failed, timeout, reject uint32
 
-	for i := 0; i < len(buckets); i++ {
-		succeed = succeed + buckets[i].succeed
-		failed += failed + buckets[i].failed
-		timeout += timeout + buckets[i].timeout
-		reject += reject + buckets[i].reject
+	for i := 0; i < window.Len(); i++ {
+		succeed += window.Bucket(i).Succeeded
+		failed += window.Bucket(i).Failed
+		timeout += window.Bucket(i).TimedOut
+		reject += window.Bucket(i).Rejected
 	}
 
 	allFailed := failed + timeout + reject
@@ -22,14 +24,110 @@ func IsOpen(buckets []bucket) uint8 {
 	return HOLD
 }
 
-func IsClosed(recoveryBucket bucket) uint8 {
-	if recoveryBucket.faileds() > 2 {
+func IsClosed(recoveryCounts Counts) Decision {
+	if recoveryCounts.Failures() > 2 {
 		return FALSE
 	}
 
-	if recoveryBucket.all() < 10 {
+	if recoveryCounts.Total() < 10 {
 		return HOLD
 	}
 
 	return TRUE
 }
+
+// DefaultPolicyOptions configures the policy built by NewDefaultPolicy. Left to
+// zero it is close to IsOpen and IsClosed, but trips once the error percentage
+// reaches 50 rather than exceeds it, and recovers after 10 successes rather
+// than 10 calls.
+type DefaultPolicyOptions struct {
+	// Minimum number of calls in the window before the breaker may trip.
+	RequestVolumeThreshold uint32
+
+	// Trips when the weighted error percentage reaches this value, 50 when
+	// zero.
+	ErrorThresholdPercent float64
+
+	// Recovers once this many trial calls succeeded while HALFOPEN, 10 when
+	// zero.
+	HalfOpenRequiredSuccesses uint32
+
+	// Reopens once more than this many weighted trial calls failed, 2 when
+	// zero. A negative value reopens on the first failure.
+	HalfOpenMaxFailures float64
+
+	// Trips when the percentage of slow calls reaches this value, whatever
+	// the error percentage, zero disables it.
+	SlowCallThresholdPercent float64
+
+	// Weights of timeouts and rejections relative to failures, 1 when zero.
+	// A negative weight leaves them out of the errors.
+	TimeoutWeight float64
+	RejectWeight  float64
+}
+
+type defaultPolicy struct {
+	DefaultPolicyOptions
+}
+
+func NewDefaultPolicy(options DefaultPolicyOptions) Policy {
+	if options.ErrorThresholdPercent <= 0 {
+		options.ErrorThresholdPercent = 50
+	}
+	if options.HalfOpenRequiredSuccesses == 0 {
+		options.HalfOpenRequiredSuccesses = 10
+	}
+	switch {
+	case options.HalfOpenMaxFailures == 0:
+		options.HalfOpenMaxFailures = 2
+	case options.HalfOpenMaxFailures < 0:
+		options.HalfOpenMaxFailures = 0
+	}
+
+	options.TimeoutWeight = weightOf(options.TimeoutWeight)
+	options.RejectWeight = weightOf(options.RejectWeight)
+
+	return defaultPolicy{options}
+}
+
+func weightOf(w float64) float64 {
+	switch {
+	case w == 0:
+		return 1
+	case w < 0:
+		return 0
+	}
+
+	return w
+}
+
+func (p defaultPolicy) errors(c Counts) float64 {
+	return float64(c.Failed) + p.TimeoutWeight*float64(c.TimedOut) + p.RejectWeight*float64(c.Rejected)
+}
+
+func (p defaultPolicy) ShouldTrip(window Window) Decision {
+	c := window.Total()
+	errors := p.errors(c)
+
+	switch {
+	case c.Total() < p.RequestVolumeThreshold:
+		return HOLD
+	case errors > 0 && errors*100/(float64(c.Succeeded)+errors) >= p.ErrorThresholdPercent:
+		return TRUE
+	case p.SlowCallThresholdPercent > 0 && c.Slow > 0 && float64(c.Slow)*100/float64(c.Total()) >= p.SlowCallThresholdPercent:
+		return TRUE
+	}
+
+	return HOLD
+}
+
+func (p defaultPolicy) ShouldRecover(c Counts) Decision {
+	switch {
+	case p.errors(c) > p.HalfOpenMaxFailures:
+		return FALSE
+	case c.Succeeded >= p.HalfOpenRequiredSuccesses:
+		return TRUE
+	}
+
+	return HOLD
+}
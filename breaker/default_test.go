@@ -5,58 +5,251 @@ import (
 )
 
 func TestIsOpenShouldReturnFalseWhenAllFailedCountIsZero(t *testing.T) {
-	if IsOpen(make([]bucket, 1)) != HOLD {
+	if IsOpen(NewWindow(Counts{})) != HOLD {
 		t.Fatalf("isOpen should return HOLD")
 	}
 }
 
 func TestIsOpenShouldReturnFalseWhenNoTriggerBreaker(t *testing.T) {
-	buckets := make([]bucket, 1)
-	buckets[0].succeed = 1
-	buckets[0].failed = 1
-	if IsOpen(buckets) != HOLD {
+	if IsOpen(NewWindow(Counts{Succeeded: 1, Failed: 1})) != HOLD {
 		t.Fatalf("isOpen should return HOLD")
 	}
 }
 
 func TestIsOpenShouldReturnTrueWhenTriggerBreaker(t *testing.T) {
-	buckets := make([]bucket, 1)
-	buckets[0].succeed = 1
-	buckets[0].failed = 2
-	if IsOpen(buckets) != TRUE {
+	if IsOpen(NewWindow(Counts{Succeeded: 1, Failed: 2})) != TRUE {
 		t.Fatalf("isOpen should return TRUE")
 	}
 }
 
 func TestIsClosedShouldReturnFalseWhenfailedsMoreThanTwo(t *testing.T) {
-	var b bucket
-	b.succeed = 1
-	b.failed = 3
-
-	if IsClosed(b) != FALSE {
+	if IsClosed(Counts{Succeeded: 1, Failed: 3}) != FALSE {
 		t.Fatalf("isClosed should return FALSE")
 	}
 
 }
 
 func TestIsClosedShouldReturnHoldWhenallLessThanTen(t *testing.T) {
-	var b bucket
-	b.succeed = 1
-	b.failed = 2
-
-	if IsClosed(b) != HOLD {
+	if IsClosed(Counts{Succeeded: 1, Failed: 2}) != HOLD {
 		t.Fatalf("isClosed should return HOLD")
 	}
 
 }
 
 func TestIsClosedShouldReturnTrue(t *testing.T) {
-	var b bucket
-	b.succeed = 10
-	b.failed = 0
-
-	if IsClosed(b) != TRUE {
+	if IsClosed(Counts{Succeeded: 10, Failed: 0}) != TRUE {
 		t.Fatalf("isClosed should return TRUE")
 	}
 
 }
+
+func TestDefaultPolicy(t *testing.T) {
+	if DefaultPolicy.ShouldTrip(NewWindow(Counts{Succeeded: 1, Failed: 2})) != TRUE {
+		t.Fatalf("default policy should trip")
+	}
+
+	if DefaultPolicy.ShouldRecover(Counts{Succeeded: 10}) != TRUE {
+		t.Fatalf("default policy should recover")
+	}
+}
+
+func TestIsOpenShouldSumAllBuckets(t *testing.T) {
+	w := NewWindow(
+		Counts{Succeeded: 3, Failed: 1},
+		Counts{Succeeded: 3, Failed: 1},
+		Counts{Succeeded: 3, Failed: 1},
+		Counts{Failed: 1},
+	)
+
+	if IsOpen(w) != HOLD {
+		t.Fatalf("isOpen should return HOLD for 4 failures out of 13")
+	}
+
+	w = NewWindow(
+		Counts{Succeeded: 1, TimedOut: 1},
+		Counts{Rejected: 1},
+		Counts{Succeeded: 1},
+	)
+
+	if IsOpen(w) != HOLD {
+		t.Fatalf("isOpen should return HOLD for 2 failures out of 4")
+	}
+}
+
+func TestDefaultPolicyOptionsRequestVolumeThreshold(t *testing.T) {
+	p := NewDefaultPolicy(DefaultPolicyOptions{
+		RequestVolumeThreshold: 3,
+		ErrorThresholdPercent:  50,
+	})
+
+	if p.ShouldTrip(NewWindow(Counts{Failed: 1}, Counts{Failed: 1})) != HOLD {
+		t.Fatalf("policy should not trip below the request volume")
+	}
+
+	if p.ShouldTrip(NewWindow(Counts{Failed: 1}, Counts{Failed: 1}, Counts{Succeeded: 1})) != TRUE {
+		t.Fatalf("policy should trip at the request volume")
+	}
+}
+
+func TestDefaultPolicyOptionsErrorThresholdPercent(t *testing.T) {
+	p := NewDefaultPolicy(DefaultPolicyOptions{ErrorThresholdPercent: 25})
+
+	cases := []struct {
+		counts Counts
+		res    Decision
+	}{
+		{Counts{}, HOLD},
+		{Counts{Succeeded: 10}, HOLD},
+		{Counts{Succeeded: 4, Failed: 1}, HOLD},
+		{Counts{Succeeded: 3, Failed: 1}, TRUE},
+		{Counts{Failed: 1}, TRUE},
+	}
+
+	for _, c := range cases {
+		if res := p.ShouldTrip(NewWindow(c.counts)); res != c.res {
+			t.Fatalf("result of %+v should be %s, got: %s", c.counts, c.res, res)
+		}
+	}
+
+	p = NewDefaultPolicy(DefaultPolicyOptions{ErrorThresholdPercent: 0})
+	if p.ShouldTrip(NewWindow(Counts{Succeeded: 10})) != HOLD {
+		t.Fatalf("policy should not trip without errors")
+	}
+	if p.ShouldTrip(NewWindow(Counts{Succeeded: 10, Failed: 1})) != HOLD {
+		t.Fatalf("policy should not trip on the first error when unset")
+	}
+}
+
+func TestDefaultPolicyOptionsWeights(t *testing.T) {
+	p := NewDefaultPolicy(DefaultPolicyOptions{ErrorThresholdPercent: 50, TimeoutWeight: -1, RejectWeight: -1})
+
+	if p.ShouldTrip(NewWindow(Counts{TimedOut: 5, Rejected: 5})) != HOLD {
+		t.Fatalf("timeouts and rejections should be ignored with negative weights")
+	}
+
+	p = NewDefaultPolicy(DefaultPolicyOptions{
+		ErrorThresholdPercent: 50,
+		TimeoutWeight:         1,
+		RejectWeight:          0.5,
+	})
+
+	if p.ShouldTrip(NewWindow(Counts{Succeeded: 2, Rejected: 2})) != HOLD {
+		t.Fatalf("policy should return HOLD for 1 weighted error out of 3")
+	}
+	if p.ShouldTrip(NewWindow(Counts{Succeeded: 2, TimedOut: 2})) != TRUE {
+		t.Fatalf("policy should return TRUE for 2 weighted errors out of 4")
+	}
+}
+
+func TestDefaultPolicyOptionsRecover(t *testing.T) {
+	p := NewDefaultPolicy(DefaultPolicyOptions{
+		HalfOpenRequiredSuccesses: 5,
+		HalfOpenMaxFailures:       1,
+		TimeoutWeight:             1,
+		RejectWeight:              -1,
+	})
+
+	cases := []struct {
+		counts Counts
+		res    Decision
+	}{
+		{Counts{}, HOLD},
+		{Counts{Succeeded: 4, Failed: 1}, HOLD},
+		{Counts{Succeeded: 5, Failed: 1}, TRUE},
+		{Counts{Succeeded: 5, Failed: 1, TimedOut: 1}, FALSE},
+		{Counts{Succeeded: 5, Failed: 1, Rejected: 1}, TRUE},
+	}
+
+	for _, c := range cases {
+		if res := p.ShouldRecover(c.counts); res != c.res {
+			t.Fatalf("result of %+v should be %s, got: %s", c.counts, c.res, res)
+		}
+	}
+}
+
+func TestDefaultPolicyOptionsSlowCallThresholdPercent(t *testing.T) {
+	p := NewDefaultPolicy(DefaultPolicyOptions{
+		RequestVolumeThreshold:   4,
+		ErrorThresholdPercent:    50,
+		SlowCallThresholdPercent: 50,
+	})
+
+	cases := []struct {
+		counts Counts
+		res    Decision
+	}{
+		{Counts{Succeeded: 3, Slow: 3}, HOLD},
+		{Counts{Succeeded: 4, Slow: 1}, HOLD},
+		{Counts{Succeeded: 4, Slow: 2}, TRUE},
+		{Counts{Succeeded: 3, Failed: 1, Slow: 2}, TRUE},
+	}
+
+	for _, c := range cases {
+		if res := p.ShouldTrip(NewWindow(c.counts)); res != c.res {
+			t.Fatalf("result of %+v should be %s, got: %s", c.counts, c.res, res)
+		}
+	}
+
+	p = NewDefaultPolicy(DefaultPolicyOptions{ErrorThresholdPercent: 50})
+	if p.ShouldTrip(NewWindow(Counts{Succeeded: 4, Slow: 4})) != HOLD {
+		t.Fatalf("slow calls should be ignored when disabled")
+	}
+}
+
+func TestNewDefaultPolicyShouldDefaultUnsetOptions(t *testing.T) {
+	p := NewDefaultPolicy(DefaultPolicyOptions{})
+
+	cases := []struct {
+		counts Counts
+		res    Decision
+		isOpen Decision
+	}{
+		{Counts{Succeeded: 10, Failed: 1}, HOLD, HOLD},
+		{Counts{Succeeded: 5, TimedOut: 5}, TRUE, HOLD},
+		{Counts{Succeeded: 5, Rejected: 5}, TRUE, HOLD},
+		{Counts{Succeeded: 5, Failed: 5}, TRUE, HOLD},
+		{Counts{Succeeded: 5, Failed: 6}, TRUE, TRUE},
+		{Counts{Succeeded: 1, Failed: 2}, TRUE, TRUE},
+	}
+
+	for _, c := range cases {
+		if res := p.ShouldTrip(NewWindow(c.counts)); res != c.res {
+			t.Fatalf("result of %+v should be %s, got: %s", c.counts, c.res, res)
+		}
+		if res := IsOpen(NewWindow(c.counts)); res != c.isOpen {
+			t.Fatalf("IsOpen of %+v should be %s, got: %s", c.counts, c.isOpen, res)
+		}
+	}
+
+	recoveries := []struct {
+		counts   Counts
+		res      Decision
+		isClosed Decision
+	}{
+		{Counts{}, HOLD, HOLD},
+		{Counts{Succeeded: 9}, HOLD, HOLD},
+		{Counts{Succeeded: 8, Failed: 2}, HOLD, TRUE},
+		{Counts{Succeeded: 10, Failed: 1, TimedOut: 1}, TRUE, TRUE},
+		{Counts{Succeeded: 1, Failed: 2, TimedOut: 1}, FALSE, FALSE},
+	}
+
+	for _, c := range recoveries {
+		if res := p.ShouldRecover(c.counts); res != c.res {
+			t.Fatalf("recovery of %+v should be %s, got: %s", c.counts, c.res, res)
+		}
+		if res := IsClosed(c.counts); res != c.isClosed {
+			t.Fatalf("IsClosed of %+v should be %s, got: %s", c.counts, c.isClosed, res)
+		}
+	}
+}
+
+func TestNewDefaultPolicyShouldReopenOnFirstFailureWhenMaxFailuresNegative(t *testing.T) {
+	p := NewDefaultPolicy(DefaultPolicyOptions{HalfOpenMaxFailures: -1})
+
+	if res := p.ShouldRecover(Counts{Succeeded: 1}); res != HOLD {
+		t.Fatalf("recovery without failures should be %s, got: %s", HOLD, res)
+	}
+	if res := p.ShouldRecover(Counts{Succeeded: 1, Failed: 1}); res != FALSE {
+		t.Fatalf("recovery after one failure should be %s, got: %s", FALSE, res)
+	}
+}
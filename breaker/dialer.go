@@ -0,0 +1,57 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Dialer guards dials with a breaker per network and address, keyed as
+// "tcp://host:port". Errors are failures, dial timeouts are timeouts and
+// dials canceled by their caller are not recorded. DialContext can be used
+// as http.Transport.DialContext.
+type Dialer struct {
+	// A zero net.Dialer when nil.
+	Base ContextDialer
+
+	// Breakers of the addresses, required.
+	Group *Group
+}
+
+func (d *Dialer) Dial(network, address string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, address)
+}
+
+func (d *Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	key := network + "://" + address
+	b := d.Group.Get(key)
+
+	e, probe, err := b.admit()
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: &OpenError{Key: key}}
+	}
+
+	base := d.Base
+	if base == nil {
+		base = &net.Dialer{}
+	}
+
+	start := time.Now()
+	conn, err := base.DialContext(ctx, network, address)
+
+	switch {
+	case err == nil:
+		b.complete(e, probe, SUCCEED, time.Since(start))
+	case errors.Is(err, context.Canceled) && ctx.Err() == context.Canceled:
+		b.release(e, probe)
+	default:
+		b.complete(e, probe, transportOutcomeOf(ctx, err), time.Since(start))
+	}
+
+	return conn, err
+}
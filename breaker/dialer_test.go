@@ -0,0 +1,116 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type dialerFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (f dialerFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+func TestDialerShouldRecordOutcomePerAddress(t *testing.T) {
+	g := NewGroup(GroupOptions{Defaults: MetricsOptions{MetricsRollingCount: 1}})
+	defer g.Stop()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+	addr := l.Addr().String()
+	defer l.Close()
+
+	d := &Dialer{Group: g}
+
+	conn, err := d.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+	conn.Close()
+	l.Close()
+
+	if _, err := d.Dial("tcp", addr); err == nil {
+		t.Fatalf("dial should be refused once closed")
+	}
+
+	if res := g.Get("tcp://" + addr).Snapshot().Total; res.Succeeded != 1 || res.Failed != 1 {
+		t.Fatalf("dial outcomes should be recorded, got: %+v", res)
+	}
+	if len(g.List()) != 1 {
+		t.Fatalf("breaker should be kept per address, got: %v", g.List())
+	}
+}
+
+func TestDialerShouldRecordTimeoutAndIgnoreCancel(t *testing.T) {
+	g := NewGroup(GroupOptions{Defaults: MetricsOptions{MetricsRollingCount: 1}})
+	defer g.Stop()
+
+	d := &Dialer{Group: g, Base: dialerFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+	})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	d.DialContext(ctx, "tcp", "test:80")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	d.DialContext(ctx, "tcp", "test:80")
+
+	if res := g.Get("tcp://test:80").Snapshot().Total; res.TimedOut != 1 || res.Total() != 1 {
+		t.Fatalf("timeout should be recorded, cancel should not, got: %+v", res)
+	}
+}
+
+func TestDialerShouldFailFastWhenOpen(t *testing.T) {
+	g := NewGroup(GroupOptions{})
+	defer g.Stop()
+
+	g.Get("tcp://test:80").cur.Store(newEpoch(OPEN))
+
+	d := &Dialer{Group: g, Base: dialerFunc(func(context.Context, string, string) (net.Conn, error) {
+		t.Fatalf("dial should not be attempted when open")
+		return nil, nil
+	})}
+
+	_, err := d.Dial("tcp", "test:80")
+
+	var openErr *OpenError
+	if errors.As(err, &openErr) == false || openErr.Key != "tcp://test:80" {
+		t.Fatalf("err should be an OpenError for the address, got: %v", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{DialContext: d.DialContext}}
+	if _, err := client.Get("http://test"); errors.Is(err, ErrOpen) == false {
+		t.Fatalf("dialer should fail fast within http.Transport, got: %v", err)
+	}
+}
+
+func TestDialerWithHTTPTransport(t *testing.T) {
+	g := NewGroup(GroupOptions{Defaults: MetricsOptions{MetricsRollingCount: 1}})
+	defer g.Stop()
+
+	s := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer s.Close()
+
+	d := &Dialer{Group: g}
+	client := &http.Client{Transport: &http.Transport{DialContext: d.DialContext}}
+
+	resp, err := client.Get(s.URL)
+	if err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+	resp.Body.Close()
+
+	if res := g.Get("tcp://" + s.Listener.Addr().String()).Snapshot().Total; res.Succeeded != 1 {
+		t.Fatalf("dial should be recorded, got: %+v", res)
+	}
+}
@@ -0,0 +1,340 @@
+package breaker
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// WrapDriver guards the connections opened by d with b, register the result
+// with sql.Register. Bad connections, deadlines and network errors are
+// failures, other errors come from the statements and are successes.
+func WrapDriver(d driver.Driver, b *Breaker) driver.Driver {
+	return &guardedDriver{Driver: d, breaker: b}
+}
+
+// WrapConnector guards the connections of c with b, use the result with
+// sql.OpenDB.
+func WrapConnector(c driver.Connector, b *Breaker) driver.Connector {
+	return &guardedConnector{connector: c, breaker: b}
+}
+
+type guardedDriver struct {
+	driver.Driver
+
+	breaker *Breaker
+}
+
+func (d *guardedDriver) Open(name string) (driver.Conn, error) {
+	var conn driver.Conn
+
+	err := d.breaker.guard(context.Background(), func() (err error) {
+		conn, err = d.Driver.Open(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &guardedConn{Conn: conn, breaker: d.breaker}, nil
+}
+
+type guardedConnector struct {
+	connector driver.Connector
+	breaker   *Breaker
+}
+
+func (c *guardedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var conn driver.Conn
+
+	err := c.breaker.guard(ctx, func() (err error) {
+		conn, err = c.connector.Connect(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &guardedConn{Conn: conn, breaker: c.breaker}, nil
+}
+
+func (c *guardedConnector) Driver() driver.Driver {
+	return &guardedDriver{Driver: c.connector.Driver(), breaker: c.breaker}
+}
+
+// Close lets sql.DB.Close release the wrapped connector.
+func (c *guardedConnector) Close() error {
+	if closer, ok := c.connector.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// guard runs fn unless b is open, in which case an OpenError is returned.
+// driver.ErrSkip and cancellations by the caller are not recorded.
+func (b *Breaker) guard(ctx context.Context, fn func() error) error {
+	e, probe, err := b.admit()
+	if err != nil {
+		return &OpenError{Key: b.Name}
+	}
+
+	start := time.Now()
+	err = fn()
+
+	var netErr net.Error
+
+	switch {
+	case err == nil:
+		b.complete(e, probe, SUCCEED, time.Since(start))
+	case err == driver.ErrSkip, errors.Is(err, context.Canceled) && ctx.Err() == context.Canceled:
+		b.release(e, probe)
+	case errors.Is(err, context.DeadlineExceeded), ctx.Err() == context.DeadlineExceeded:
+		b.complete(e, probe, TIMEOUT, time.Since(start))
+	case errors.As(err, &netErr) && netErr.Timeout():
+		b.complete(e, probe, TIMEOUT, time.Since(start))
+	case errors.Is(err, driver.ErrBadConn), errors.As(err, &netErr):
+		b.complete(e, probe, FAILED, time.Since(start))
+	default:
+		b.complete(e, probe, SUCCEED, time.Since(start))
+	}
+
+	return err
+}
+
+// guardedConn implements the optional interfaces of database/sql, falling
+// back to the plain methods of the wrapped connection or to driver.ErrSkip.
+type guardedConn struct {
+	driver.Conn
+
+	breaker *Breaker
+}
+
+func (c *guardedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *guardedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+
+	err := c.breaker.guard(ctx, func() (err error) {
+		if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+			stmt, err = p.PrepareContext(ctx, query)
+		} else {
+			stmt, err = c.Conn.Prepare(query)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &guardedStmt{Stmt: stmt, breaker: c.breaker}, nil
+}
+
+func (c *guardedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *guardedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	var tx driver.Tx
+
+	err := c.breaker.guard(ctx, func() (err error) {
+		if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+			tx, err = b.BeginTx(ctx, opts)
+		} else if opts != (driver.TxOptions{}) {
+			err = errors.New("breaker: driver does not support transaction options")
+		} else {
+			tx, err = c.Conn.Begin()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &guardedTx{Tx: tx, breaker: c.breaker}, nil
+}
+
+func (c *guardedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	var res driver.Result
+
+	err := c.breaker.guard(ctx, func() (err error) {
+		switch e := c.Conn.(type) {
+		case driver.ExecerContext:
+			res, err = e.ExecContext(ctx, query, args)
+		case driver.Execer:
+			var values []driver.Value
+			if values, err = valuesOf(args); err == nil {
+				res, err = e.Exec(query, values)
+			}
+		default:
+			err = driver.ErrSkip
+		}
+		return err
+	})
+
+	return res, err
+}
+
+func (c *guardedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+
+	err := c.breaker.guard(ctx, func() (err error) {
+		switch q := c.Conn.(type) {
+		case driver.QueryerContext:
+			rows, err = q.QueryContext(ctx, query, args)
+		case driver.Queryer:
+			var values []driver.Value
+			if values, err = valuesOf(args); err == nil {
+				rows, err = q.Query(query, values)
+			}
+		default:
+			err = driver.ErrSkip
+		}
+		return err
+	})
+
+	return rows, err
+}
+
+func (c *guardedConn) Ping(ctx context.Context) error {
+	p, ok := c.Conn.(driver.Pinger)
+	if ok == false {
+		return nil
+	}
+
+	return c.breaker.guard(ctx, func() error {
+		return p.Ping(ctx)
+	})
+}
+
+func (c *guardedConn) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+
+	return driver.ErrSkip
+}
+
+func (c *guardedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+func (c *guardedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+
+	return true
+}
+
+type guardedStmt struct {
+	driver.Stmt
+
+	breaker *Breaker
+}
+
+func (s *guardedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), namedValuesOf(args))
+}
+
+func (s *guardedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var res driver.Result
+
+	err := s.breaker.guard(ctx, func() (err error) {
+		if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+			res, err = e.ExecContext(ctx, args)
+			return err
+		}
+
+		var values []driver.Value
+		if values, err = valuesOf(args); err == nil {
+			res, err = s.Stmt.Exec(values)
+		}
+		return err
+	})
+
+	return res, err
+}
+
+func (s *guardedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), namedValuesOf(args))
+}
+
+func (s *guardedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+
+	err := s.breaker.guard(ctx, func() (err error) {
+		if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+			rows, err = q.QueryContext(ctx, args)
+			return err
+		}
+
+		var values []driver.Value
+		if values, err = valuesOf(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+		return err
+	})
+
+	return rows, err
+}
+
+func (s *guardedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+
+	return driver.ErrSkip
+}
+
+type guardedTx struct {
+	driver.Tx
+
+	breaker *Breaker
+}
+
+// Commit rolls the transaction back when the breaker is open, so that the
+// connection does not go back to the pool in the middle of it. Rollback is
+// never rejected.
+func (t *guardedTx) Commit() error {
+	err := t.breaker.guard(context.Background(), t.Tx.Commit)
+
+	var openErr *OpenError
+	if errors.As(err, &openErr) {
+		t.Tx.Rollback()
+	}
+
+	return err
+}
+
+func valuesOf(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("breaker: driver does not support named parameters")
+		}
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
+
+func namedValuesOf(values []driver.Value) []driver.NamedValue {
+	args := make([]driver.NamedValue, len(values))
+
+	for i, v := range values {
+		args[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+
+	return args
+}
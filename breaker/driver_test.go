@@ -0,0 +1,292 @@
+package breaker
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver answers every statement with one row holding the query, or
+// with err when set.
+type fakeDriver struct {
+	sync.Mutex
+
+	err       error
+	commits   int
+	rollbacks int
+}
+
+func (d *fakeDriver) fail() error {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.err
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	if err := d.fail(); err != nil {
+		return nil, err
+	}
+
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if err := c.d.fail(); err != nil {
+		return nil, err
+	}
+
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	if err := c.d.fail(); err != nil {
+		return nil, err
+	}
+
+	return &fakeTx{d: c.d}, nil
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.d.fail(); err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.d.fail(); err != nil {
+		return nil, err
+	}
+
+	return &fakeRows{value: s.query}, nil
+}
+
+type fakeRows struct {
+	value string
+	done  bool
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"query"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+
+	r.done = true
+	dest[0] = r.value
+	return nil
+}
+
+type fakeTx struct {
+	d *fakeDriver
+}
+
+func (t *fakeTx) Commit() error {
+	t.d.Lock()
+	defer t.d.Unlock()
+
+	t.d.commits++
+	return t.d.err
+}
+
+func (t *fakeTx) Rollback() error {
+	t.d.Lock()
+	defer t.d.Unlock()
+
+	t.d.rollbacks++
+	return nil
+}
+
+type fakeConnector struct {
+	d *fakeDriver
+}
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.d.Open("")
+}
+
+func (c fakeConnector) Driver() driver.Driver {
+	return c.d
+}
+
+type closingConnector struct {
+	fakeConnector
+
+	closed int
+}
+
+func (c *closingConnector) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestWrapConnectorShouldCloseConnector(t *testing.T) {
+	c := &closingConnector{fakeConnector: fakeConnector{d: &fakeDriver{}}}
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	if err := sql.OpenDB(WrapConnector(c, b)).Close(); err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+	if c.closed != 1 {
+		t.Fatalf("connector should be closed once, got: %d", c.closed)
+	}
+
+	db := sql.OpenDB(WrapConnector(fakeConnector{d: &fakeDriver{}}, b))
+	if err := db.Close(); err != nil {
+		t.Fatalf("connector without Close should close, got: %v", err)
+	}
+}
+
+func TestWrapDriverShouldGuardStatements(t *testing.T) {
+	d := &fakeDriver{}
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	// Drivers can not be registered twice when tests are repeated.
+	name := fmt.Sprintf("breaker-test-%d", time.Now().UnixNano())
+	sql.Register(name, WrapDriver(d, b))
+
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+	defer db.Close()
+
+	var res string
+	if err := db.QueryRow("select 1").Scan(&res); err != nil || res != "select 1" {
+		t.Fatalf("query should go through, got: %q, %v", res, err)
+	}
+	if _, err := db.Exec("insert 1"); err != nil {
+		t.Fatalf("exec should go through, got: %v", err)
+	}
+
+	// Open, then prepare and query, then prepare and exec on the pooled
+	// connection.
+	if outcome := b.current().drain(); outcome.succeed != 5 || outcome.all() != 5 {
+		t.Fatalf("every operation should be recorded, got: %+v", outcome)
+	}
+
+	b.cur.Store(newEpoch(OPEN))
+
+	_, err = db.Exec("insert 2")
+
+	var openErr *OpenError
+	if errors.As(err, &openErr) == false || errors.Is(err, ErrOpen) == false {
+		t.Fatalf("err should be an OpenError, got: %v", err)
+	}
+}
+
+func TestWrapConnectorShouldClassifyErrors(t *testing.T) {
+	d := &fakeDriver{}
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	db := sql.OpenDB(WrapConnector(fakeConnector{d: d}, b))
+	defer db.Close()
+	db.SetMaxIdleConns(1)
+
+	if err := db.Ping(); err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+	b.current().drain()
+
+	cases := []struct {
+		err error
+		res Counts
+	}{
+		{errors.New("duplicate key"), Counts{Succeeded: 1}},
+		{&net.OpError{Op: "read", Err: errors.New("connection reset")}, Counts{Failed: 1}},
+		{context.DeadlineExceeded, Counts{TimedOut: 1}},
+	}
+
+	for _, c := range cases {
+		d.Lock()
+		d.err = c.err
+		d.Unlock()
+
+		db.Exec("insert 1")
+
+		if res := b.current().drain(); res.counts() != c.res {
+			t.Fatalf("outcome of %v should be %+v, got: %+v", c.err, c.res, res.counts())
+		}
+	}
+
+	d.Lock()
+	d.err = driver.ErrBadConn
+	d.Unlock()
+
+	db.Exec("insert 1")
+
+	if res := b.current().drain(); res.failed == 0 || res.succeed != 0 {
+		t.Fatalf("bad connections should be failures, got: %+v", res)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db.ExecContext(ctx, "insert 1")
+
+	if res := b.current().drain(); res.all() != 0 {
+		t.Fatalf("canceled call should not be recorded, got: %+v", res)
+	}
+}
+
+func TestWrapDriverShouldRollbackWhenCommitRejected(t *testing.T) {
+	d := &fakeDriver{}
+	b := newBreakerInState(CLOSED, MetricsOptions{RecoverInterval: time.Hour})
+
+	db := sql.OpenDB(WrapConnector(fakeConnector{d: d}, b))
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+
+	b.cur.Store(newEpoch(OPEN))
+
+	if err := tx.Commit(); errors.Is(err, ErrOpen) == false {
+		t.Fatalf("commit should be rejected, got: %v", err)
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	if d.commits != 0 || d.rollbacks != 1 {
+		t.Fatalf("rejected commit should roll back, got: %d commits, %d rollbacks", d.commits, d.rollbacks)
+	}
+}
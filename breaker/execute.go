@@ -0,0 +1,160 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+var ErrOpen = errors.New("breaker: circuit is open")
+
+// OpenError is returned instead of ErrOpen when the call was rejected by
+// the breaker of a group, errors.Is(err, ErrOpen) holds.
+type OpenError struct {
+	Key string
+}
+
+func (e *OpenError) Error() string {
+	return "breaker: circuit is open for " + e.Key
+}
+
+func (e *OpenError) Unwrap() error {
+	return ErrOpen
+}
+
+// Allow admits or rejects a call up front. The returned done must be called
+// exactly once with the call's outcome, the call lasting until then;
+// outcomes of calls admitted before the last state change are discarded.
+func (b *Breaker) Allow() (done func(outcome Outcome), err error) {
+	e, probe, err := b.admit()
+	if err != nil {
+		return nil, err
+	}
+
+	var called uint32
+	start := time.Now()
+
+	return func(outcome Outcome) {
+		if atomic.AddUint32(&called, 1) != 1 {
+			panic("breaker: done called more than once")
+		}
+
+		b.complete(e, probe, outcome, time.Since(start))
+	}, nil
+}
+
+func (b *Breaker) admit() (*epoch, bool, error) {
+	e := b.current()
+
+	switch e.state {
+	case OPEN, FORCEDOPEN:
+		e.addShort()
+		return nil, false, ErrOpen
+	case HALFOPEN:
+		if b.MaxHalfOpenRequests == 0 {
+			return e, false, nil
+		}
+
+		if atomic.AddInt32(&e.probes, 1) > int32(b.MaxHalfOpenRequests) {
+			atomic.AddInt32(&e.probes, -1)
+			e.addShort()
+			return nil, false, ErrOpen
+		}
+
+		return e, true, nil
+	case CLOSED:
+		if b.ThrottleK > 0 && b.throttled(e) {
+			return nil, false, ErrOpen
+		}
+	case RAMPUP:
+		if rand.Float64() >= b.RampUp.fraction(time.Since(e.since), b.RampUpInterval) {
+			e.addShort()
+			return nil, false, ErrOpen
+		}
+	}
+
+	return e, false, nil
+}
+
+func (b *Breaker) complete(e *epoch, probe bool, outcome Outcome, d time.Duration) {
+	b.release(e, probe)
+	b.record(e, outcome, d)
+}
+
+// release gives back the probe of a call whose outcome says nothing about
+// the downstream, such as one canceled by its caller.
+func (b *Breaker) release(e *epoch, probe bool) {
+	if probe {
+		atomic.AddInt32(&e.probes, -1)
+	}
+}
+
+func (b *Breaker) record(e *epoch, outcome Outcome, d time.Duration) {
+	if outcome > REJECT || e.state == FORCEDCLOSED {
+		return
+	}
+
+	e.add(outcome)
+
+	if d > 0 {
+		e.addLatency(d)
+	}
+
+	if b.SlowCallThreshold > 0 && d >= b.SlowCallThreshold {
+		e.addSlow()
+	}
+
+	if b.streaks != nil {
+		b.observe(e, outcome)
+	}
+}
+
+// Do runs fn unless the breaker is open and records its outcome: nil is
+// SUCCEED, a deadline is TIMEOUT, ErrOpen from a nested breaker is REJECT
+// and any other error is FAILED.
+func (b *Breaker) Do(ctx context.Context, fn func(context.Context) error) error {
+	_, err := Execute(b, ctx, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+
+	return err
+}
+
+// Execute is Do for calls returning a value. The zero value of T is
+// returned along with ErrOpen when the breaker is open.
+func Execute[T any](b *Breaker, ctx context.Context, fn func(context.Context) (T, error)) (T, error) {
+	e, probe, err := b.admit()
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	start := time.Now()
+
+	defer func() {
+		if r := recover(); r != nil {
+			b.complete(e, probe, FAILED, time.Since(start))
+			panic(r)
+		}
+	}()
+
+	res, err := fn(ctx)
+	b.complete(e, probe, outcomeOf(ctx, err), time.Since(start))
+
+	return res, err
+}
+
+func outcomeOf(ctx context.Context, err error) Outcome {
+	switch {
+	case err == nil:
+		return SUCCEED
+	case errors.Is(err, ErrOpen):
+		return REJECT
+	case errors.Is(err, context.DeadlineExceeded), ctx.Err() == context.DeadlineExceeded:
+		return TIMEOUT
+	}
+
+	return FAILED
+}
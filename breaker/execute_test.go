@@ -0,0 +1,315 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newBreakerInState(state State, metricsOptions MetricsOptions) *Breaker {
+	b := newBreaker(metricsOptions, DefaultPolicy)
+	b.cur.Store(newEpoch(state))
+	return b
+}
+
+func TestBreakerMethodDoShouldReturnErrOpenWhenOpen(t *testing.T) {
+	b := newBreakerInState(OPEN, MetricsOptions{})
+
+	called := false
+	err := b.Do(context.Background(), func(context.Context) error {
+		called = true
+		return nil
+	})
+
+	if err != ErrOpen {
+		t.Fatalf("err should be ErrOpen, got: %v", err)
+	}
+	if called == true {
+		t.Fatalf("fn should not be called when open")
+	}
+	if res := b.current().drain(); res.all() != 0 {
+		t.Fatalf("nothing should be recorded when open, got: %d", res.all())
+	}
+}
+
+func TestBreakerMethodDoRecordOutcome(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	cases := []struct {
+		err error
+		res Counts
+	}{
+		{nil, Counts{Succeeded: 1}},
+		{fmt.Errorf("test"), Counts{Failed: 1}},
+		{context.DeadlineExceeded, Counts{TimedOut: 1}},
+		{fmt.Errorf("wrapped: %w", context.DeadlineExceeded), Counts{TimedOut: 1}},
+		{ErrOpen, Counts{Rejected: 1}},
+	}
+
+	for _, c := range cases {
+		err := b.Do(context.Background(), func(context.Context) error { return c.err })
+		if err != c.err {
+			t.Fatalf("err should be %v, got: %v", c.err, err)
+		}
+		if res := b.current().drain(); res.counts() != c.res {
+			t.Fatalf("outcome of %v should be %+v, got: %+v", c.err, c.res, res)
+		}
+	}
+}
+
+func TestBreakerMethodDoRecordTimeoutWhenContextExpired(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	b.Do(ctx, func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("i/o timeout")
+	})
+
+	if res := b.current().drain(); res.timeout != 1 {
+		t.Fatalf("outcome should be TIMEOUT, got: %+v", res)
+	}
+}
+
+func TestBreakerMethodDoShouldNotBlockAfterStop(t *testing.T) {
+	metricsOptions := MetricsOptions{
+		MetricsRollingCount: 5,
+		MetricsInterval:     1 * time.Second,
+		ReceiveInterval:     1 * time.Second,
+		UpdateStateInterval: 1 * time.Second,
+		RecoverInterval:     5 * time.Second,
+	}
+
+	b := NewBreakerWithDefault(metricsOptions)
+	b.Stop()
+
+	if err := b.Do(context.Background(), func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+}
+
+func TestExecuteShouldReturnZeroValueAndErrOpenWhenOpen(t *testing.T) {
+	b := newBreakerInState(OPEN, MetricsOptions{})
+
+	res, err := Execute(b, context.Background(), func(context.Context) (string, error) {
+		return "test", nil
+	})
+
+	if res != "" || err != ErrOpen {
+		t.Fatalf("res should be empty, err should be ErrOpen, got: %q, %v", res, err)
+	}
+}
+
+func TestExecuteReturnValueAndRecordOutcome(t *testing.T) {
+	b := newBreakerInState(HALFOPEN, MetricsOptions{})
+
+	res, err := Execute(b, context.Background(), func(context.Context) (int, error) {
+		return 1, nil
+	})
+
+	if res != 1 || err != nil {
+		t.Fatalf("res should be 1, err should be nil, got: %d, %v", res, err)
+	}
+	if outcome := b.current().drain(); outcome.succeed != 1 {
+		t.Fatalf("outcome should be SUCCEED, got: %+v", outcome)
+	}
+
+	e := fmt.Errorf("test")
+	res, err = Execute(b, context.Background(), func(context.Context) (int, error) {
+		return 2, e
+	})
+
+	if res != 2 || err != e {
+		t.Fatalf("res should be 2, err should be %v, got: %d, %v", e, res, err)
+	}
+	if outcome := b.current().drain(); outcome.failed != 1 {
+		t.Fatalf("outcome should be FAILED, got: %+v", outcome)
+	}
+}
+
+func TestBreakerMethodAllowShouldReturnErrOpenWhenOpen(t *testing.T) {
+	b := newBreakerInState(OPEN, MetricsOptions{})
+
+	done, err := b.Allow()
+	if done != nil || err != ErrOpen {
+		t.Fatalf("done should be nil, err should be ErrOpen, got: %v", err)
+	}
+}
+
+func TestBreakerMethodAllowRecordOutcome(t *testing.T) {
+	b := newBreakerInState(HALFOPEN, MetricsOptions{})
+
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		done(TIMEOUT)
+		close(finished)
+	}()
+	<-finished
+
+	if outcome := b.current().drain(); outcome.timeout != 1 {
+		t.Fatalf("outcome should be TIMEOUT, got: %+v", outcome)
+	}
+}
+
+func TestBreakerMethodAllowShouldDiscardOutcomeAfterStateChanged(t *testing.T) {
+	b := newBreakerInState(HALFOPEN, MetricsOptions{RecoverInterval: time.Hour})
+	defer b.Stop()
+
+	done, _ := b.Allow()
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	done(SUCCEED)
+
+	if outcome := b.current().drain(); outcome.all() != 0 {
+		t.Fatalf("outcome should be discarded, got: %+v", outcome)
+	}
+}
+
+func TestBreakerMethodAllowDoneShouldPanicWhenCalledTwice(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	done, _ := b.Allow()
+	done(SUCCEED)
+
+	res := func() (r bool) {
+		defer func() {
+			if recover() != nil {
+				r = true
+			}
+		}()
+
+		done(SUCCEED)
+
+		return r
+	}()
+
+	if res != true {
+		t.Fatalf("done should panic when called twice")
+	}
+	if outcome := b.current().drain(); outcome.succeed != 1 {
+		t.Fatalf("only one outcome should be recorded, got: %+v", outcome)
+	}
+}
+
+func TestExecuteShouldRecordFailedWhenPanic(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	func() {
+		defer func() { recover() }()
+
+		Execute(b, context.Background(), func(context.Context) (int, error) {
+			panic("test")
+		})
+	}()
+
+	if outcome := b.current().drain(); outcome.failed != 1 {
+		t.Fatalf("outcome should be FAILED, got: %+v", outcome)
+	}
+}
+
+func TestBreakerMethodAllowLimitHalfOpenRequests(t *testing.T) {
+	b := newBreakerInState(HALFOPEN, MetricsOptions{MaxHalfOpenRequests: 1})
+
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatalf("first probe should be admitted, got: %v", err)
+	}
+
+	if _, err := b.Allow(); err != ErrOpen {
+		t.Fatalf("second probe should be rejected, got: %v", err)
+	}
+	if b.Active() != true {
+		t.Fatalf("breaker should be active when probes are exhausted")
+	}
+
+	done(SUCCEED)
+
+	if outcome := b.current().drain(); outcome.succeed != 1 {
+		t.Fatalf("probe outcome should be SUCCEED, got: %+v", outcome)
+	}
+	if b.Active() != false {
+		t.Fatalf("breaker should not be active when probes are released")
+	}
+
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("probe should be admitted after release, got: %v", err)
+	}
+}
+
+func TestExecuteRecordSlowCall(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{SlowCallThreshold: 5 * time.Millisecond})
+
+	b.Do(context.Background(), func(context.Context) error { return nil })
+
+	if res := b.current().drain(); res.succeed != 1 || res.slow != 0 {
+		t.Fatalf("fast call should not be slow, got: %+v", res)
+	}
+
+	b.Do(context.Background(), func(context.Context) error {
+		time.Sleep(5 * time.Millisecond)
+		return errors.New("test")
+	})
+
+	if res := b.current().drain(); res.failed != 1 || res.slow != 1 {
+		t.Fatalf("slow call should be counted next to its outcome, got: %+v", res)
+	}
+
+	done, _ := b.Allow()
+	time.Sleep(5 * time.Millisecond)
+	done(SUCCEED)
+
+	if res := b.current().drain(); res.succeed != 1 || res.slow != 1 {
+		t.Fatalf("slow call should be counted with Allow, got: %+v", res)
+	}
+
+	b.record(b.current(), REJECT+1, time.Hour)
+
+	if res := b.current().drain(); res.slow != 0 {
+		t.Fatalf("unknown outcome should not be counted, got: %+v", res)
+	}
+}
+
+func TestExecuteShouldNotRecordSlowCallWithoutThreshold(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	b.Do(context.Background(), func(context.Context) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+
+	if res := b.current().drain(); res.slow != 0 {
+		t.Fatalf("slow calls should not be counted without threshold, got: %+v", res)
+	}
+}
+
+func TestBreakerMethodAllowShouldCountShortCircuited(t *testing.T) {
+	b := newBreakerInState(OPEN, MetricsOptions{MetricsRollingCount: 1})
+
+	b.Allow()
+	b.Do(context.Background(), func(context.Context) error { return nil })
+
+	b.Lock()
+	b.flush()
+	b.Unlock()
+
+	if res := b.buckets[0].counts(); res.ShortCircuited != 2 || res.Total() != 0 {
+		t.Fatalf("rejected calls should be counted apart, got: %+v", res)
+	}
+
+	b = newBreakerInState(HALFOPEN, MetricsOptions{MaxHalfOpenRequests: 1})
+	b.Allow()
+	b.Allow()
+
+	if res := b.current().drain(); res.short != 1 {
+		t.Fatalf("calls over the probe limit should be counted, got: %+v", res)
+	}
+}
@@ -0,0 +1,187 @@
+package breaker
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type GroupOptions struct {
+	// Options of the breakers created by Get, their Name defaulting to the
+	// key.
+	Defaults MetricsOptions
+
+	// Options replacing the defaults for the given keys.
+	Overrides map[string]MetricsOptions
+
+	// Shared by the breakers of the group, DefaultPolicy when nil.
+	Policy Policy
+
+	// Breakers not got for this long are stopped and removed, zero keeps
+	// them until Remove.
+	IdleTTL time.Duration
+}
+
+type member struct {
+	breaker *Breaker
+	used    atomic.Int64
+}
+
+// Group lazily creates a started breaker per key, typically one per
+// downstream host or endpoint.
+type Group struct {
+	sync.RWMutex
+
+	members map[string]*member
+	sweeper *time.Timer
+	stopped bool
+
+	GroupOptions
+}
+
+func NewGroup(opts GroupOptions) *Group {
+	if opts.Policy == nil {
+		opts.Policy = DefaultPolicy
+	}
+
+	return &Group{
+		members:      make(map[string]*member),
+		GroupOptions: opts,
+	}
+}
+
+// Get returns the breaker of key, creating it on first use. It is safe for
+// concurrent use and only takes a read lock once the breaker exists.
+func (g *Group) Get(key string) *Breaker {
+	now := time.Now().UnixNano()
+
+	g.RLock()
+	m := g.members[key]
+	g.RUnlock()
+
+	if m == nil {
+		m = g.add(key, now)
+	}
+
+	// Only refreshed every eighth of the TTL to keep callers of a busy key
+	// from writing to the same cache line on every call.
+	if ttl := int64(g.IdleTTL); ttl > 0 && now-m.used.Load() > ttl/8 {
+		m.used.Store(now)
+	}
+
+	return m.breaker
+}
+
+func (g *Group) add(key string, now int64) *member {
+	g.Lock()
+	defer g.Unlock()
+
+	if m := g.members[key]; m != nil {
+		return m
+	}
+
+	opts, ok := g.Overrides[key]
+	if ok == false {
+		opts = g.Defaults
+	}
+	if opts.Name == "" {
+		opts.Name = key
+	}
+
+	m := &member{breaker: newBreaker(opts, g.Policy)}
+	m.used.Store(now)
+
+	if g.stopped == false {
+		m.breaker.Start()
+		g.members[key] = m
+
+		if g.IdleTTL > 0 && g.sweeper == nil {
+			g.sweeper = time.AfterFunc(g.IdleTTL, g.sweep)
+		}
+	}
+
+	return m
+}
+
+// List returns the breakers of the group ordered by key.
+func (g *Group) List() []*Breaker {
+	g.RLock()
+	defer g.RUnlock()
+
+	keys := make([]string, 0, len(g.members))
+	for key := range g.members {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	res := make([]*Breaker, len(keys))
+	for i, key := range keys {
+		res[i] = g.members[key].breaker
+	}
+
+	return res
+}
+
+// Remove stops and removes the breaker of key, a later Get creates a new
+// one.
+func (g *Group) Remove(key string) {
+	g.Lock()
+	m := g.members[key]
+	delete(g.members, key)
+	g.Unlock()
+
+	if m != nil {
+		m.breaker.Stop()
+	}
+}
+
+// Stop stops and removes every breaker, breakers got afterwards are not
+// started.
+func (g *Group) Stop() {
+	g.Lock()
+	members := g.members
+	g.members = make(map[string]*member)
+	g.stopped = true
+	if g.sweeper != nil {
+		g.sweeper.Stop()
+		g.sweeper = nil
+	}
+	g.Unlock()
+
+	for _, m := range members {
+		m.breaker.Stop()
+	}
+}
+
+func (g *Group) sweep() {
+	idle := g.evict(time.Now())
+
+	g.Lock()
+	g.sweeper = nil
+	if len(g.members) > 0 && g.stopped == false {
+		g.sweeper = time.AfterFunc(g.IdleTTL/2, g.sweep)
+	}
+	g.Unlock()
+
+	for _, b := range idle {
+		b.Stop()
+	}
+}
+
+// evict removes the breakers idle at now.
+func (g *Group) evict(now time.Time) []*Breaker {
+	var idle []*Breaker
+
+	g.Lock()
+	defer g.Unlock()
+
+	for key, m := range g.members {
+		if now.UnixNano()-m.used.Load() >= int64(g.IdleTTL) {
+			idle = append(idle, m.breaker)
+			delete(g.members, key)
+		}
+	}
+
+	return idle
+}
@@ -0,0 +1,120 @@
+package breaker
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGroupMethodGetShouldCreateBreakerOnce(t *testing.T) {
+	g := NewGroup(GroupOptions{
+		Defaults: MetricsOptions{MetricsRollingCount: 5},
+		Overrides: map[string]MetricsOptions{
+			"b": {Name: "override", MetricsRollingCount: 2},
+		},
+	})
+	defer g.Stop()
+
+	res := make([]*Breaker, 8)
+
+	var wg sync.WaitGroup
+	for i := range res {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			res[i] = g.Get("a")
+		}(i)
+	}
+	wg.Wait()
+
+	for _, b := range res {
+		if b != res[0] {
+			t.Fatalf("breaker should be created once per key")
+		}
+	}
+
+	if res[0].Name != "a" || len(res[0].buckets) != 5 || res[0].policy == nil {
+		t.Fatalf("breaker should use the defaults named after its key, got: %+v", res[0].MetricsOptions)
+	}
+	if b := g.Get("b"); b.Name != "override" || len(b.buckets) != 2 {
+		t.Fatalf("breaker should use its override, got: %+v", b.MetricsOptions)
+	}
+}
+
+func TestGroupMethodList(t *testing.T) {
+	g := NewGroup(GroupOptions{})
+	defer g.Stop()
+
+	c, a, b := g.Get("c"), g.Get("a"), g.Get("b")
+
+	res := g.List()
+	if len(res) != 3 || res[0] != a || res[1] != b || res[2] != c {
+		t.Fatalf("breakers should be listed by key, got: %v", res)
+	}
+
+	g.Remove("b")
+
+	if res := g.List(); len(res) != 2 || res[0] != a || res[1] != c {
+		t.Fatalf("removed breaker should not be listed, got: %v", res)
+	}
+	if g.Get("b") == b {
+		t.Fatalf("removed breaker should be created again")
+	}
+}
+
+func TestGroupMethodEvictIdleBreakers(t *testing.T) {
+	g := NewGroup(GroupOptions{IdleTTL: time.Hour})
+	defer g.Stop()
+
+	now := time.Now()
+
+	a := g.Get("a")
+	g.members["a"].used.Store(now.Add(-2 * time.Hour).UnixNano())
+	g.Get("b")
+
+	res := g.evict(now)
+	if len(res) != 1 || res[0] != a {
+		t.Fatalf("only idle breaker should be evicted, got: %v", res)
+	}
+	if res := g.List(); len(res) != 1 || res[0].Name != "b" {
+		t.Fatalf("idle breaker should be removed, got: %v", res)
+	}
+}
+
+func TestGroupShouldStopIdleBreakers(t *testing.T) {
+	g := NewGroup(GroupOptions{
+		Defaults: MetricsOptions{MetricsRollingCount: 1, MetricsInterval: time.Second},
+		IdleTTL:  10 * time.Millisecond,
+	})
+	defer g.Stop()
+
+	b := g.Get("a")
+
+	for i := 0; i < 100 && b.ctx.Err() == nil; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if b.ctx.Err() == nil {
+		t.Fatalf("idle breaker should be stopped")
+	}
+	if res := g.List(); len(res) != 0 {
+		t.Fatalf("idle breaker should be removed, got: %v", res)
+	}
+}
+
+func TestGroupMethodStop(t *testing.T) {
+	g := NewGroup(GroupOptions{IdleTTL: time.Hour})
+
+	b := g.Get("a")
+	g.Stop()
+
+	if res := g.List(); len(res) != 0 {
+		t.Fatalf("breakers should be removed, got: %v", res)
+	}
+	if b.ctx.Err() == nil {
+		t.Fatalf("breaker should be stopped")
+	}
+	if g.Get("a") == b || len(g.List()) != 0 {
+		t.Fatalf("breakers got after Stop should not be kept")
+	}
+}
@@ -0,0 +1,191 @@
+package breaker
+
+import (
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Latencies are counted in microseconds in log-linear buckets: values below
+// 16µs exactly, larger ones with 8 buckets per power of two, which keeps
+// the relative error under 12.5% up to the last bucket, ending at about
+// 2m14s. Longer latencies are all counted in the last bucket.
+const (
+	histogramSubBits = 3
+	histogramSize    = 200
+)
+
+type histogram struct {
+	counts [histogramSize]uint32
+	total  uint32
+	max    uint64
+}
+
+func histogramIndex(v uint64) int {
+	if v < 1<<(histogramSubBits+1) {
+		return int(v)
+	}
+
+	e := bits.Len64(v) - histogramSubBits - 1
+	i := e<<histogramSubBits + int(v>>uint(e))
+
+	if i >= histogramSize {
+		return histogramSize - 1
+	}
+
+	return i
+}
+
+// histogramValue returns the highest value counted in the i-th bucket.
+func histogramValue(i int) uint64 {
+	if i < 1<<(histogramSubBits+1) {
+		return uint64(i)
+	}
+
+	e := uint(i>>histogramSubBits - 1)
+	m := uint64(i&(1<<histogramSubBits-1) + 1<<histogramSubBits)
+
+	return (m+1)<<e - 1
+}
+
+func (h *histogram) record(d time.Duration) {
+	atomic.AddUint32(&h.counts[histogramIndex(uint64(d/time.Microsecond))], 1)
+	atomic.AddUint32(&h.total, 1)
+
+	for {
+		max := atomic.LoadUint64(&h.max)
+		if uint64(d) <= max || atomic.CompareAndSwapUint64(&h.max, max, uint64(d)) {
+			return
+		}
+	}
+}
+
+// drainInto moves the recorded latencies into dst, it is safe against
+// concurrent calls to record.
+func (h *histogram) drainInto(dst *histogram) {
+	if atomic.SwapUint32(&h.total, 0) == 0 {
+		return
+	}
+
+	for i := range h.counts {
+		if n := atomic.SwapUint32(&h.counts[i], 0); n > 0 {
+			dst.counts[i] += n
+			dst.total += n
+		}
+	}
+
+	if max := atomic.SwapUint64(&h.max, 0); max > dst.max {
+		dst.max = max
+	}
+}
+
+func (h *histogram) merge(o *histogram) {
+	for i, n := range o.counts {
+		h.counts[i] += n
+	}
+
+	h.total += o.total
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+func (h *histogram) reset() {
+	*h = histogram{}
+}
+
+func (h *histogram) count() uint64 {
+	var res uint64
+
+	for _, n := range h.counts {
+		res += uint64(n)
+	}
+
+	return res
+}
+
+// quantile returns the latency under which a fraction q of the calls fall,
+// bounded by the highest latency recorded.
+func (h *histogram) quantile(q float64) time.Duration {
+	n := h.count()
+	if n == 0 {
+		return 0
+	}
+
+	rank := uint64(q*float64(n) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen uint64
+	for i, c := range h.counts {
+		seen += uint64(c)
+		if seen >= rank {
+			d := time.Duration(histogramValue(i)) * time.Microsecond
+			if max := time.Duration(h.max); d > max {
+				return max
+			}
+			return d
+		}
+	}
+
+	return time.Duration(h.max)
+}
+
+type Latency struct {
+	Count uint64
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+
+	h *histogram
+}
+
+// Quantile returns the latency under which a fraction q of the calls fall.
+func (l Latency) Quantile(q float64) time.Duration {
+	if l.h == nil {
+		return 0
+	}
+
+	return l.h.quantile(q)
+}
+
+// Mean is approximated from the middle of the histogram buckets.
+func (l Latency) Mean() time.Duration {
+	if l.h == nil || l.Count == 0 {
+		return 0
+	}
+
+	var sum, low float64
+	for i, c := range l.h.counts {
+		high := float64(histogramValue(i))
+		sum += float64(c) * (low + high) / 2
+		low = high + 1
+	}
+
+	mean := time.Duration(sum/float64(l.Count)) * time.Microsecond
+	if mean > l.Max {
+		return l.Max
+	}
+
+	return mean
+}
+
+func (h *histogram) latency() Latency {
+	n := h.count()
+	if n == 0 {
+		return Latency{}
+	}
+
+	c := *h
+
+	return Latency{
+		Count: n,
+		P50:   h.quantile(0.50),
+		P90:   h.quantile(0.90),
+		P99:   h.quantile(0.99),
+		Max:   time.Duration(h.max),
+		h:     &c,
+	}
+}
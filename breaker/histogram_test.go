@@ -0,0 +1,125 @@
+package breaker
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHistogramIndexShouldMatchValue(t *testing.T) {
+	for _, v := range []uint64{0, 1, 15, 16, 17, 31, 32, 100, 1000, 123456, 60000000} {
+		i := histogramIndex(v)
+		if histogramValue(i) < v {
+			t.Fatalf("bucket of %d should hold it, got: %d", v, histogramValue(i))
+		}
+		if i > 0 && histogramValue(i-1) >= v {
+			t.Fatalf("previous bucket of %d should not hold it, got: %d", v, histogramValue(i-1))
+		}
+		if hi := histogramValue(i); float64(hi-v) > float64(v)/8 {
+			t.Fatalf("bucket of %d should be within 12.5%%, got: %d", v, hi)
+		}
+	}
+
+	if hi := time.Duration(histogramValue(histogramSize-1)) * time.Microsecond; hi < 2*time.Minute || hi > 3*time.Minute {
+		t.Fatalf("last bucket should end at about 2m14s, got: %v", hi)
+	}
+
+	if i := histogramIndex(1 << 40); i != histogramSize-1 {
+		t.Fatalf("large value should go to the last bucket, got: %d", i)
+	}
+}
+
+func TestHistogramMethodQuantile(t *testing.T) {
+	var h histogram
+
+	if res := h.latency(); res != (Latency{}) {
+		t.Fatalf("empty histogram should report zeros, got: %+v", res)
+	}
+
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+
+	res := h.latency()
+	if res.Count != 100 {
+		t.Fatalf("count should be 100, got: %d", res.Count)
+	}
+	if res.Max != 100*time.Millisecond {
+		t.Fatalf("max should be 100ms, got: %v", res.Max)
+	}
+
+	for _, c := range []struct {
+		got, want time.Duration
+	}{
+		{res.P50, 50 * time.Millisecond},
+		{res.P90, 90 * time.Millisecond},
+		{res.P99, 99 * time.Millisecond},
+	} {
+		if c.got < c.want || c.got > c.want+c.want/8 {
+			t.Fatalf("quantile should be about %v, got: %v", c.want, c.got)
+		}
+	}
+}
+
+func TestHistogramMethodDrainIntoShouldNotLoseConcurrentRecords(t *testing.T) {
+	var h, dst histogram
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				h.record(time.Millisecond)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		h.drainInto(&dst)
+	}
+	h.drainInto(&dst)
+
+	if n := dst.count(); n != 4000 {
+		t.Fatalf("all records should be drained, got: %d", n)
+	}
+}
+
+func TestLatencyMethodQuantileAndMean(t *testing.T) {
+	var h histogram
+
+	if res := h.latency(); res.Quantile(0.5) != 0 || res.Mean() != 0 {
+		t.Fatalf("empty latency should report zeros, got: %v, %v", res.Quantile(0.5), res.Mean())
+	}
+
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+
+	res := h.latency()
+	if q := res.Quantile(0.25); q < 25*time.Millisecond || q > 29*time.Millisecond {
+		t.Fatalf("p25 should be about 25ms, got: %v", q)
+	}
+	if q := res.Quantile(1); q != res.Max {
+		t.Fatalf("p100 should be max, got: %v", q)
+	}
+	if m := res.Mean(); m < 48*time.Millisecond || m > 53*time.Millisecond {
+		t.Fatalf("mean should be about 50.5ms, got: %v", m)
+	}
+
+	h.record(time.Second)
+	if q := res.Quantile(1); q != res.Max {
+		t.Fatalf("latency should not change with the histogram, got: %v", q)
+	}
+}
@@ -0,0 +1,198 @@
+// Package hystrixstream streams the metrics of a set of breakers as
+// server-sent events in the format of the Hystrix dashboard.
+package hystrixstream
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/wowsoso/pkg/breaker"
+)
+
+const DefaultInterval = 500 * time.Millisecond
+
+type Percentiles struct {
+	P0   int64 `json:"0"`
+	P25  int64 `json:"25"`
+	P50  int64 `json:"50"`
+	P75  int64 `json:"75"`
+	P90  int64 `json:"90"`
+	P95  int64 `json:"95"`
+	P99  int64 `json:"99"`
+	P995 int64 `json:"99.5"`
+	P100 int64 `json:"100"`
+}
+
+// Command is the HystrixCommand event of one breaker, latencies being in
+// milliseconds.
+type Command struct {
+	Type                 string `json:"type"`
+	Name                 string `json:"name"`
+	Group                string `json:"group"`
+	CurrentTime          int64  `json:"currentTime"`
+	IsCircuitBreakerOpen bool   `json:"isCircuitBreakerOpen"`
+	ErrorPercentage      int64  `json:"errorPercentage"`
+	ErrorCount           uint64 `json:"errorCount"`
+	RequestCount         uint64 `json:"requestCount"`
+
+	RollingCountCollapsedRequests  uint64 `json:"rollingCountCollapsedRequests"`
+	RollingCountExceptionsThrown   uint64 `json:"rollingCountExceptionsThrown"`
+	RollingCountFailure            uint64 `json:"rollingCountFailure"`
+	RollingCountFallbackFailure    uint64 `json:"rollingCountFallbackFailure"`
+	RollingCountFallbackRejection  uint64 `json:"rollingCountFallbackRejection"`
+	RollingCountFallbackSuccess    uint64 `json:"rollingCountFallbackSuccess"`
+	RollingCountResponsesFromCache uint64 `json:"rollingCountResponsesFromCache"`
+	RollingCountSemaphoreRejected  uint64 `json:"rollingCountSemaphoreRejected"`
+	RollingCountShortCircuited     uint64 `json:"rollingCountShortCircuited"`
+	RollingCountSuccess            uint64 `json:"rollingCountSuccess"`
+	RollingCountThreadPoolRejected uint64 `json:"rollingCountThreadPoolRejected"`
+	RollingCountTimeout            uint64 `json:"rollingCountTimeout"`
+
+	CurrentConcurrentExecutionCount uint64 `json:"currentConcurrentExecutionCount"`
+
+	LatencyExecuteMean int64       `json:"latencyExecute_mean"`
+	LatencyExecute     Percentiles `json:"latencyExecute"`
+	LatencyTotalMean   int64       `json:"latencyTotal_mean"`
+	LatencyTotal       Percentiles `json:"latencyTotal"`
+
+	PropertyValueCircuitBreakerRequestVolumeThreshold             uint32 `json:"propertyValue_circuitBreakerRequestVolumeThreshold"`
+	PropertyValueCircuitBreakerSleepWindowInMilliseconds          int64  `json:"propertyValue_circuitBreakerSleepWindowInMilliseconds"`
+	PropertyValueCircuitBreakerErrorThresholdPercentage           uint32 `json:"propertyValue_circuitBreakerErrorThresholdPercentage"`
+	PropertyValueCircuitBreakerForceOpen                          bool   `json:"propertyValue_circuitBreakerForceOpen"`
+	PropertyValueCircuitBreakerForceClosed                        bool   `json:"propertyValue_circuitBreakerForceClosed"`
+	PropertyValueCircuitBreakerEnabled                            bool   `json:"propertyValue_circuitBreakerEnabled"`
+	PropertyValueExecutionIsolationStrategy                       string `json:"propertyValue_executionIsolationStrategy"`
+	PropertyValueExecutionIsolationThreadTimeoutInMilliseconds    int64  `json:"propertyValue_executionIsolationThreadTimeoutInMilliseconds"`
+	PropertyValueExecutionIsolationThreadInterruptOnTimeout       bool   `json:"propertyValue_executionIsolationThreadInterruptOnTimeout"`
+	PropertyValueExecutionIsolationThreadPoolKeyOverride          string `json:"propertyValue_executionIsolationThreadPoolKeyOverride"`
+	PropertyValueExecutionIsolationSemaphoreMaxConcurrentRequests uint32 `json:"propertyValue_executionIsolationSemaphoreMaxConcurrentRequests"`
+	PropertyValueFallbackIsolationSemaphoreMaxConcurrentRequests  uint32 `json:"propertyValue_fallbackIsolationSemaphoreMaxConcurrentRequests"`
+	PropertyValueMetricsRollingStatisticalWindowInMilliseconds    int64  `json:"propertyValue_metricsRollingStatisticalWindowInMilliseconds"`
+	PropertyValueRequestCacheEnabled                              bool   `json:"propertyValue_requestCacheEnabled"`
+	PropertyValueRequestLogEnabled                                bool   `json:"propertyValue_requestLogEnabled"`
+
+	ReportingHosts uint32 `json:"reportingHosts"`
+}
+
+// CommandOf builds the event of b from its rolling buckets. As in Hystrix,
+// calls rejected by the breaker are only counted as short-circuited, not as
+// requests or errors. Breakers do not track calls in flight, so
+// CurrentConcurrentExecutionCount is left to zero.
+func CommandOf(b *breaker.Breaker) Command {
+	s := b.Snapshot()
+
+	errors := uint64(s.Total.Failures())
+	requests := uint64(s.Total.Total())
+
+	var percentage int64
+	if requests > 0 {
+		percentage = int64(errors * 100 / requests)
+	}
+
+	latency := percentilesOf(s.Latency)
+	mean := milliseconds(s.Latency.Mean())
+
+	return Command{
+		Type:                 "HystrixCommand",
+		Name:                 s.Name,
+		Group:                s.Name,
+		CurrentTime:          time.Now().UnixNano() / int64(time.Millisecond),
+		IsCircuitBreakerOpen: s.State == breaker.OPEN || s.State == breaker.HALFOPEN || s.State == breaker.FORCEDOPEN,
+		ErrorPercentage:      percentage,
+		ErrorCount:           errors,
+		RequestCount:         requests,
+
+		RollingCountFailure:           uint64(s.Total.Failed),
+		RollingCountSemaphoreRejected: uint64(s.Total.Rejected),
+		RollingCountShortCircuited:    uint64(s.Total.ShortCircuited),
+		RollingCountSuccess:           uint64(s.Total.Succeeded),
+		RollingCountTimeout:           uint64(s.Total.TimedOut),
+
+		LatencyExecuteMean: mean,
+		LatencyExecute:     latency,
+		LatencyTotalMean:   mean,
+		LatencyTotal:       latency,
+
+		PropertyValueCircuitBreakerSleepWindowInMilliseconds:          milliseconds(b.RecoverInterval),
+		PropertyValueCircuitBreakerForceOpen:                          s.State == breaker.FORCEDOPEN,
+		PropertyValueCircuitBreakerForceClosed:                        s.State == breaker.FORCEDCLOSED,
+		PropertyValueCircuitBreakerEnabled:                            s.State != breaker.DISABLED,
+		PropertyValueExecutionIsolationStrategy:                       "SEMAPHORE",
+		PropertyValueExecutionIsolationSemaphoreMaxConcurrentRequests: b.MaxHalfOpenRequests,
+		PropertyValueMetricsRollingStatisticalWindowInMilliseconds:    milliseconds(time.Duration(b.MetricsRollingCount) * b.MetricsInterval),
+
+		ReportingHosts: 1,
+	}
+}
+
+func percentilesOf(l breaker.Latency) Percentiles {
+	return Percentiles{
+		P0:   milliseconds(l.Quantile(0)),
+		P25:  milliseconds(l.Quantile(0.25)),
+		P50:  milliseconds(l.P50),
+		P75:  milliseconds(l.Quantile(0.75)),
+		P90:  milliseconds(l.P90),
+		P95:  milliseconds(l.Quantile(0.95)),
+		P99:  milliseconds(l.P99),
+		P995: milliseconds(l.Quantile(0.995)),
+		P100: milliseconds(l.Max),
+	}
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// Handler streams an event for every breaker returned by list at each
+// interval, DefaultInterval when not positive, until the client goes away.
+func Handler(list func() []*breaker.Breaker, interval time.Duration) http.Handler {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if ok == false {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			for _, b := range list() {
+				data, err := json.Marshal(CommandOf(b))
+				if err != nil {
+					continue
+				}
+
+				if _, err := w.Write(append(append([]byte("data: "), data...), '\n', '\n')); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-t.C:
+			}
+		}
+	})
+}
+
+// HandlerOf streams the events of a fixed set of breakers.
+func HandlerOf(interval time.Duration, breakers ...*breaker.Breaker) http.Handler {
+	return Handler(func() []*breaker.Breaker {
+		return breakers
+	}, interval)
+}
@@ -0,0 +1,141 @@
+package hystrixstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wowsoso/pkg/breaker"
+	"github.com/wowsoso/pkg/testkit"
+)
+
+func TestCommandOf(t *testing.T) {
+	b := breaker.NewBreakerWithDefault(breaker.MetricsOptions{
+		Name:                "test",
+		MetricsRollingCount: 10,
+		MetricsInterval:     time.Second,
+		RecoverInterval:     5 * time.Second,
+	})
+	defer b.Stop()
+
+	for _, outcome := range []breaker.Outcome{breaker.SUCCEED, breaker.SUCCEED, breaker.SUCCEED, breaker.FAILED, breaker.TIMEOUT} {
+		done, _ := b.Allow()
+		done(outcome)
+	}
+
+	res := CommandOf(b)
+
+	if res.Type != "HystrixCommand" || res.Name != "test" || res.IsCircuitBreakerOpen != false {
+		t.Fatalf("command should be of closed test, got: %+v", res)
+	}
+	if res.RequestCount != 5 || res.ErrorCount != 2 || res.ErrorPercentage != 40 {
+		t.Fatalf("2 errors out of 5 requests should be 40%%, got: %d, %d, %d", res.ErrorCount, res.RequestCount, res.ErrorPercentage)
+	}
+	if res.RollingCountSuccess != 3 || res.RollingCountFailure != 1 || res.RollingCountTimeout != 1 || res.RollingCountShortCircuited != 0 {
+		t.Fatalf("rolling counts should match outcomes, got: %+v", res)
+	}
+	if res.PropertyValueMetricsRollingStatisticalWindowInMilliseconds != 10000 || res.PropertyValueCircuitBreakerSleepWindowInMilliseconds != 5000 {
+		t.Fatalf("properties should match options, got: %+v", res)
+	}
+}
+
+func TestCommandOfShouldNotCountShortCircuitedAsErrors(t *testing.T) {
+	b := breaker.NewBreakerWithDefault(breaker.MetricsOptions{Name: "test", MetricsRollingCount: 10})
+	defer b.Stop()
+
+	for _, outcome := range []breaker.Outcome{breaker.SUCCEED, breaker.SUCCEED, breaker.SUCCEED, breaker.FAILED} {
+		done, _ := b.Allow()
+		done(outcome)
+	}
+
+	b.ForceOpen()
+	b.Allow()
+	b.Allow()
+
+	res := CommandOf(b)
+
+	if res.RollingCountShortCircuited != 2 {
+		t.Fatalf("short-circuited count should be 2, got: %d", res.RollingCountShortCircuited)
+	}
+	if res.RequestCount != 4 || res.ErrorCount != 1 || res.ErrorPercentage != 25 {
+		t.Fatalf("1 error out of 4 requests should be 25%%, got: %d, %d, %d", res.ErrorCount, res.RequestCount, res.ErrorPercentage)
+	}
+}
+
+func TestHandlerShouldStreamCommands(t *testing.T) {
+	a := breaker.NewBreakerWithDefault(breaker.MetricsOptions{Name: "a", MetricsRollingCount: 1})
+	b := breaker.NewBreakerWithDefault(breaker.MetricsOptions{Name: "b", MetricsRollingCount: 1})
+	defer a.Stop()
+	defer b.Stop()
+
+	done, _ := a.Allow()
+	done(breaker.SUCCEED)
+
+	s := httptest.NewServer(HandlerOf(10*time.Millisecond, a, b))
+	defer s.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", s.URL, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type should be text/event-stream, got: %q", ct)
+	}
+
+	var names []string
+
+	r := bufio.NewReader(resp.Body)
+	for len(names) < 4 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("err should be nil, got: %v", err)
+		}
+		if line == "\n" {
+			continue
+		}
+		if strings.HasPrefix(line, "data: ") == false {
+			t.Fatalf("event should be data, got: %q", line)
+		}
+
+		var res map[string]interface{}
+		if err := json.Unmarshal([]byte(line[len("data: "):]), &res); err != nil {
+			t.Fatalf("event should be json, got: %v", err)
+		}
+
+		for _, key := range []string{"type", "isCircuitBreakerOpen", "errorPercentage", "requestCount", "rollingCountSuccess", "rollingCountShortCircuited", "latencyExecute", "latencyTotal_mean"} {
+			if _, ok := res[key]; ok == false {
+				t.Fatalf("event should have %s, got: %v", key, res)
+			}
+		}
+		if _, ok := res["latencyExecute"].(map[string]interface{})["99.5"]; ok == false {
+			t.Fatalf("latency should have percentiles, got: %v", res["latencyExecute"])
+		}
+
+		names = append(names, res["name"].(string))
+	}
+
+	if strings.Join(names, ",") != "a,b,a,b" {
+		t.Fatalf("every breaker should be streamed at each interval, got: %v", names)
+	}
+}
+
+func TestHandlerShouldFailWithoutFlusher(t *testing.T) {
+	rw := testkit.NewResponseWriterMock()
+
+	HandlerOf(0).ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+
+	if rw.Status != http.StatusInternalServerError {
+		t.Fatalf("status should be 500, got: %d", rw.Status)
+	}
+}
@@ -0,0 +1,155 @@
+package breaker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Middleware guards next with b. Responses with a 5xx status or a panic are
+// failures, others are successes. While open, requests are served by
+// fallback or, when nil, answered with 503 and a Retry-After of the time
+// left before recovery, or of the RecoverInterval when unknown.
+func Middleware(b *Breaker, next, fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e, probe, err := b.admit()
+		if err != nil {
+			if fallback != nil {
+				fallback.ServeHTTP(w, r)
+				return
+			}
+
+			wait := b.RecoverInterval
+			if at := b.recoveryDue(); at.IsZero() == false {
+				wait = time.Until(at)
+			}
+
+			retry := int64((wait + time.Second - 1) / time.Second)
+			if retry < 1 {
+				retry = 1
+			}
+
+			w.Header().Set("Retry-After", strconv.FormatInt(retry, 10))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		rw, sw := wrapWriter(w)
+		start := time.Now()
+
+		defer func() {
+			if p := recover(); p != nil {
+				b.complete(e, probe, FAILED, time.Since(start))
+				panic(p)
+			}
+		}()
+
+		next.ServeHTTP(rw, r)
+
+		outcome := SUCCEED
+		switch {
+		case sw.status >= 500:
+			outcome = FAILED
+		case errors.Is(r.Context().Err(), context.DeadlineExceeded):
+			outcome = TIMEOUT
+		}
+
+		b.complete(e, probe, outcome, time.Since(start))
+	})
+}
+
+// statusWriter keeps the status written by the handler.
+type statusWriter struct {
+	http.ResponseWriter
+
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *statusWriter) flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *statusWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// wrapWriter wraps w in a statusWriter that is an http.Flusher or an
+// http.Hijacker only when w is one.
+func wrapWriter(w http.ResponseWriter) (http.ResponseWriter, *statusWriter) {
+	sw := &statusWriter{ResponseWriter: w}
+
+	_, flusher := w.(http.Flusher)
+	_, hijacker := w.(http.Hijacker)
+
+	switch {
+	case flusher && hijacker:
+		return flushHijackWriter{sw}, sw
+	case flusher:
+		return flushWriter{sw}, sw
+	case hijacker:
+		return hijackWriter{sw}, sw
+	}
+
+	return sw, sw
+}
+
+type flushWriter struct {
+	*statusWriter
+}
+
+func (w flushWriter) Flush() {
+	w.flush()
+}
+
+type hijackWriter struct {
+	*statusWriter
+}
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+type flushHijackWriter struct {
+	*statusWriter
+}
+
+func (w flushHijackWriter) Flush() {
+	w.flush()
+}
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
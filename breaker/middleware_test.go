@@ -0,0 +1,173 @@
+package breaker
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/wowsoso/pkg/testkit"
+)
+
+func TestMiddlewareShouldRecordOutcomeByStatus(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	cases := []struct {
+		handler http.HandlerFunc
+		res     Counts
+	}{
+		{func(w http.ResponseWriter, r *http.Request) {}, Counts{Succeeded: 1}},
+		{func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("test")) }, Counts{Succeeded: 1}},
+		{func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }, Counts{Succeeded: 1}},
+		{func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) }, Counts{Failed: 1}},
+		{func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(http.StatusOK)
+		}, Counts{Failed: 1}},
+	}
+
+	for i, c := range cases {
+		Middleware(b, c.handler, nil).ServeHTTP(testkit.NewResponseWriterMock(), httptest.NewRequest("GET", "/", nil))
+
+		if res := b.current().drain(); res.counts() != c.res {
+			t.Fatalf("outcome of case %d should be %+v, got: %+v", i, c.res, res.counts())
+		}
+	}
+}
+
+func TestMiddlewareShouldRecordFailedWhenPanic(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	func() {
+		defer func() { recover() }()
+
+		Middleware(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("test")
+		}), nil).ServeHTTP(testkit.NewResponseWriterMock(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	if res := b.current().drain(); res.failed != 1 {
+		t.Fatalf("outcome should be FAILED, got: %+v", res)
+	}
+}
+
+func TestMiddlewareShouldServeFallbackOrUnavailableWhenOpen(t *testing.T) {
+	b := newBreakerInState(OPEN, MetricsOptions{RecoverInterval: 1500 * time.Millisecond})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("next should not be called when open")
+	})
+
+	rw := testkit.NewResponseWriterMock()
+	Middleware(b, next, nil).ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+
+	if rw.Status != http.StatusServiceUnavailable || rw.HeaderMap.Get("Retry-After") != "2" {
+		t.Fatalf("response should be 503 with Retry-After 2, got: %d, %q", rw.Status, rw.HeaderMap.Get("Retry-After"))
+	}
+
+	rw = testkit.NewResponseWriterMock()
+	Middleware(b, next, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})).ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+
+	if rw.Status != http.StatusTeapot {
+		t.Fatalf("response should be served by fallback, got: %d", rw.Status)
+	}
+}
+
+func TestMiddlewareShouldSupportFlusherAndHijacker(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	calls := make(chan string, 2)
+
+	s := httptest.NewServer(Middleware(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flush" {
+			w.(http.Flusher).Flush()
+			calls <- "flush"
+			return
+		}
+
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			calls <- err.Error()
+			return
+		}
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n")
+		rw.Flush()
+		conn.Close()
+		calls <- "hijack"
+	}), nil))
+	defer s.Close()
+
+	for _, path := range []string{"/flush", "/hijack"} {
+		resp, err := http.Get(s.URL + path)
+		if err != nil {
+			t.Fatalf("err should be nil, got: %v", err)
+		}
+		resp.Body.Close()
+
+		if res := <-calls; res != path[1:] {
+			t.Fatalf("writer should support %s, got: %s", path[1:], res)
+		}
+	}
+
+	// Outcomes are recorded once the handler returns.
+	var res bucket
+	for i := 0; i < 100 && res.succeed < 2; i++ {
+		time.Sleep(time.Millisecond)
+		res.merge(b.current().drain())
+	}
+
+	if res.succeed != 2 {
+		t.Fatalf("outcomes should be SUCCEED, got: %+v", res)
+	}
+}
+
+func TestMiddlewareShouldNotClaimFlusherOrHijackerWhenUnsupported(t *testing.T) {
+	b := newBreakerInState(CLOSED, MetricsOptions{})
+
+	Middleware(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); ok {
+			t.Fatalf("writer should not be a Flusher when the mock is not")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Fatalf("writer should not be a Hijacker when the mock is not")
+		}
+		if err := http.NewResponseController(w).Flush(); errors.Is(err, http.ErrNotSupported) == false {
+			t.Fatalf("flush should not be supported by the mock, got: %v", err)
+		}
+	}), nil).ServeHTTP(testkit.NewResponseWriterMock(), httptest.NewRequest("GET", "/", nil))
+
+	rec := httptest.NewRecorder()
+
+	Middleware(b, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(http.Flusher); ok == false {
+			t.Fatalf("writer should be a Flusher when the recorder is")
+		}
+		if _, ok := w.(http.Hijacker); ok {
+			t.Fatalf("writer should not be a Hijacker when the recorder is not")
+		}
+		w.(http.Flusher).Flush()
+	}), nil).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	if rec.Flushed == false {
+		t.Fatalf("flush should reach the recorder")
+	}
+}
+
+func TestMiddlewareShouldDeriveRetryAfterFromBackoff(t *testing.T) {
+	b := newBreaker(MetricsOptions{RecoverInterval: 10 * time.Second, RecoverBackoff: 3}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.changeState(OPEN)
+
+	rw := testkit.NewResponseWriterMock()
+	Middleware(b, http.NotFoundHandler(), nil).ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+
+	if res := rw.HeaderMap.Get("Retry-After"); res != "30" {
+		t.Fatalf("Retry-After should follow the backed off recovery, got: %q", res)
+	}
+}
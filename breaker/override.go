@@ -0,0 +1,73 @@
+package breaker
+
+import (
+	"time"
+)
+
+// ForceOpen rejects every call until released by another override. The
+// recovery timer and the policy do not move the breaker out of a forced
+// state.
+func (b *Breaker) ForceOpen() {
+	b.override(FORCEDOPEN)
+}
+
+// ForceClosed admits every call without recording it, until released.
+func (b *Breaker) ForceClosed() {
+	b.override(FORCEDCLOSED)
+}
+
+// Disable admits every call and keeps recording outcomes in the rolling
+// window, without ever acting on them, until released.
+func (b *Breaker) Disable() {
+	b.override(DISABLED)
+}
+
+// Reset releases any forced state and moves the breaker to CLOSED with
+// empty buckets and no backoff.
+func (b *Breaker) Reset() {
+	b.override(CLOSED)
+}
+
+func (b *Breaker) override(state State) {
+	b.Lock()
+
+	from := b.State()
+	if from == state && state != CLOSED {
+		b.Unlock()
+		return
+	}
+
+	b.flush()
+	counts := b.countsOf(from)
+
+	switch state {
+	case CLOSED:
+		b.setStateClosed()
+		b.recoveryBucket.reset()
+	default:
+		b.cur.Store(newEpoch(state))
+		b.recoverAt = time.Time{}
+		b.rampUntil = time.Time{}
+		b.throttle.Store(0)
+	}
+
+	if from == state {
+		b.Unlock()
+		return
+	}
+
+	b.totals.change(from, state)
+
+	t := Transition{
+		Name:   b.Name,
+		From:   from,
+		To:     state,
+		At:     time.Now(),
+		Reason: REASONMANUAL,
+		Counts: counts,
+	}
+
+	b.Unlock()
+
+	b.publish(t)
+}
@@ -0,0 +1,129 @@
+package breaker
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBreakerMethodForceOpen(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, UpdateStateInterval: time.Second, RecoverInterval: time.Second}, PolicyFuncs{
+		Trip:    func(Window) Decision { return TRUE },
+		Recover: func(Counts) Decision { return TRUE },
+	})
+	b.sched = newScheduler()
+
+	var mu sync.Mutex
+	var res []Transition
+	done := make(chan struct{}, 8)
+	b.Subscribe(func(t Transition) {
+		mu.Lock()
+		res = append(res, t)
+		mu.Unlock()
+		done <- struct{}{}
+	})
+
+	b.ForceOpen()
+	<-done
+
+	if b.State() != FORCEDOPEN || b.Active() != true {
+		t.Fatalf("state should be FORCEDOPEN, got: %s", b.State())
+	}
+	if _, err := b.Allow(); err != ErrOpen {
+		t.Fatalf("calls should be rejected, got: %v", err)
+	}
+
+	now := time.Now().Add(time.Hour)
+	b.nextUpdate = now
+	b.run(now)
+	b.changeState(HALFOPEN)
+	b.changeState(CLOSED)
+
+	if b.State() != FORCEDOPEN {
+		t.Fatalf("policy and recovery should not override FORCEDOPEN, got: %s", b.State())
+	}
+
+	b.ForceOpen()
+	b.Reset()
+	<-done
+
+	if b.State() != CLOSED {
+		t.Fatalf("state should be CLOSED once reset, got: %s", b.State())
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(res) != 2 || res[0].To != FORCEDOPEN || res[1].From != FORCEDOPEN || res[1].To != CLOSED {
+		t.Fatalf("transitions should be to FORCEDOPEN and back, got: %+v", res)
+	}
+	for _, r := range res {
+		if r.Reason != REASONMANUAL {
+			t.Fatalf("reason should be manual, got: %s", r.Reason)
+		}
+	}
+}
+
+func TestBreakerMethodForceClosedAndDisable(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, UpdateStateInterval: time.Second}, PolicyFuncs{
+		Trip:    func(Window) Decision { return TRUE },
+		Recover: func(Counts) Decision { return TRUE },
+	})
+	b.sched = newScheduler()
+
+	for _, c := range []struct {
+		force    func()
+		state    State
+		recorded uint32
+	}{
+		{b.ForceClosed, FORCEDCLOSED, 0},
+		{b.Disable, DISABLED, 1},
+	} {
+		c.force()
+
+		done, err := b.Allow()
+		if err != nil {
+			t.Fatalf("calls should be admitted when %s, got: %v", c.state, err)
+		}
+		done(FAILED)
+
+		now := time.Now().Add(time.Hour)
+		b.nextFlush = now
+		b.nextUpdate = now
+		b.run(now)
+		b.changeState(OPEN)
+
+		if b.State() != c.state {
+			t.Fatalf("policy should not override %s, got: %s", c.state, b.State())
+		}
+		if res := b.Snapshot(); res.Total.Failed != c.recorded || res.Totals.Failed != uint64(c.recorded) {
+			t.Fatalf("%s should record %d outcomes, got: %+v, %+v", c.state, c.recorded, res.Total, res.Totals)
+		}
+	}
+
+	b.Reset()
+
+	if res := b.Snapshot(); res.State != CLOSED || res.Total != (Counts{}) {
+		t.Fatalf("reset should clear the window, got: %v, %+v", res.State, res.Total)
+	}
+}
+
+func TestBreakerMethodResetShouldClearBackoff(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, RecoverInterval: time.Minute, RecoverBackoff: 2}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.changeState(OPEN)
+	b.Reset()
+
+	if b.State() != CLOSED || b.reopens != 0 || b.recoverAt.IsZero() == false {
+		t.Fatalf("reset should clear the backoff, got: %s, %d, %v", b.State(), b.reopens, b.recoverAt)
+	}
+
+	b.changeState(OPEN)
+
+	if b.openFor != time.Minute {
+		t.Fatalf("open duration should restart from the recover interval, got: %v", b.openFor)
+	}
+}
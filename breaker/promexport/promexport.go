@@ -0,0 +1,138 @@
+// Package promexport writes the metrics of a set of breakers in the
+// Prometheus text exposition format.
+package promexport
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/wowsoso/pkg/breaker"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var states = []breaker.State{
+	breaker.CLOSED,
+	breaker.OPEN,
+	breaker.HALFOPEN,
+	breaker.RAMPUP,
+	breaker.FORCEDOPEN,
+	breaker.FORCEDCLOSED,
+	breaker.DISABLED,
+}
+
+var outcomes = []breaker.Outcome{
+	breaker.SUCCEED,
+	breaker.FAILED,
+	breaker.TIMEOUT,
+	breaker.REJECT,
+}
+
+// Handler serves the metrics of the breakers returned by list on every
+// scrape.
+func Handler(list func() []*breaker.Breaker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		Write(w, list())
+	})
+}
+
+// HandlerOf serves the metrics of a fixed set of breakers.
+func HandlerOf(breakers ...*breaker.Breaker) http.Handler {
+	return Handler(func() []*breaker.Breaker {
+		return breakers
+	})
+}
+
+func Write(w io.Writer, breakers []*breaker.Breaker) error {
+	snapshots := make([]breaker.Snapshot, len(breakers))
+	for i, b := range breakers {
+		snapshots[i] = b.Snapshot()
+	}
+
+	return WriteSnapshots(w, snapshots)
+}
+
+func WriteSnapshots(w io.Writer, snapshots []breaker.Snapshot) error {
+	bw := bufio.NewWriter(w)
+
+	header(bw, "breaker_state", "gauge", "Whether the breaker is in the given state.")
+	for _, s := range snapshots {
+		for _, state := range states {
+			value := "0"
+			if s.State == state {
+				value = "1"
+			}
+			sample(bw, "breaker_state", value, "name", s.Name, "state", state.String())
+		}
+	}
+
+	header(bw, "breaker_outcomes_total", "counter", "Outcomes of the calls guarded by the breaker.")
+	for _, s := range snapshots {
+		counts := []uint64{s.Totals.Succeeded, s.Totals.Failed, s.Totals.TimedOut, s.Totals.Rejected}
+		for i, outcome := range outcomes {
+			sample(bw, "breaker_outcomes_total", strconv.FormatUint(counts[i], 10), "name", s.Name, "outcome", outcome.String())
+		}
+	}
+
+	header(bw, "breaker_slow_calls_total", "counter", "Calls lasting at least the slow call threshold.")
+	for _, s := range snapshots {
+		sample(bw, "breaker_slow_calls_total", strconv.FormatUint(s.Totals.Slow, 10), "name", s.Name)
+	}
+
+	header(bw, "breaker_short_circuited_total", "counter", "Calls rejected by the breaker itself.")
+	for _, s := range snapshots {
+		sample(bw, "breaker_short_circuited_total", strconv.FormatUint(s.Totals.ShortCircuited, 10), "name", s.Name)
+	}
+
+	header(bw, "breaker_transitions_total", "counter", "State changes of the breaker.")
+	for _, s := range snapshots {
+		changes := make([]breaker.StateChange, 0, len(s.Totals.Transitions))
+		for change := range s.Totals.Transitions {
+			changes = append(changes, change)
+		}
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].From != changes[j].From {
+				return changes[i].From < changes[j].From
+			}
+			return changes[i].To < changes[j].To
+		})
+
+		for _, change := range changes {
+			sample(bw, "breaker_transitions_total", strconv.FormatUint(s.Totals.Transitions[change], 10),
+				"name", s.Name, "from", change.From.String(), "to", change.To.String())
+		}
+	}
+
+	return bw.Flush()
+}
+
+func header(w *bufio.Writer, metric, kind, help string) {
+	w.WriteString("# HELP " + metric + " " + help + "\n")
+	w.WriteString("# TYPE " + metric + " " + kind + "\n")
+}
+
+// sample writes one line, labels being given as name and value pairs.
+func sample(w *bufio.Writer, metric string, value string, labels ...string) {
+	w.WriteString(metric)
+
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i == 0 {
+			w.WriteByte('{')
+		} else {
+			w.WriteByte(',')
+		}
+		w.WriteString(labels[i] + `="` + labelEscaper.Replace(labels[i+1]) + `"`)
+	}
+	if len(labels) > 0 {
+		w.WriteByte('}')
+	}
+
+	w.WriteString(" " + value + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
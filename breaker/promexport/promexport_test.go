@@ -0,0 +1,222 @@
+package promexport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/wowsoso/pkg/breaker"
+)
+
+type family struct {
+	kind    string
+	samples map[string]string
+}
+
+// parse reads the text exposition format, samples being keyed by their
+// labels rendered as name=value pairs joined by commas.
+func parse(r io.Reader) (map[string]*family, error) {
+	res := make(map[string]*family)
+
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := s.Text()
+
+		if strings.HasPrefix(line, "# ") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) < 4 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+				return nil, fmt.Errorf("invalid comment: %q", line)
+			}
+			if res[fields[2]] == nil {
+				res[fields[2]] = &family{samples: make(map[string]string)}
+			}
+			if fields[1] == "TYPE" {
+				res[fields[2]].kind = fields[3]
+			}
+			continue
+		}
+
+		metric, labels, value, err := parseSample(line)
+		if err != nil {
+			return nil, err
+		}
+
+		f := res[metric]
+		if f == nil || f.kind == "" {
+			return nil, fmt.Errorf("sample before its TYPE: %q", line)
+		}
+		if _, ok := f.samples[labels]; ok {
+			return nil, fmt.Errorf("duplicated sample: %q", line)
+		}
+		f.samples[labels] = value
+	}
+
+	return res, s.Err()
+}
+
+func parseSample(line string) (string, string, string, error) {
+	i := strings.IndexAny(line, "{ ")
+	if i <= 0 {
+		return "", "", "", fmt.Errorf("invalid sample: %q", line)
+	}
+
+	metric, rest := line[:i], line[i:]
+
+	var labels []string
+	if rest[0] == '{' {
+		rest = rest[1:]
+		for rest[0] != '}' {
+			eq := strings.Index(rest, `="`)
+			if eq <= 0 {
+				return "", "", "", fmt.Errorf("invalid label: %q", line)
+			}
+			name := rest[:eq]
+			rest = rest[eq+2:]
+
+			var value strings.Builder
+			for {
+				if rest == "" {
+					return "", "", "", fmt.Errorf("unterminated label: %q", line)
+				}
+				c := rest[0]
+				rest = rest[1:]
+				if c == '"' {
+					break
+				}
+				if c == '\\' {
+					switch rest[0] {
+					case 'n':
+						c = '\n'
+					default:
+						c = rest[0]
+					}
+					rest = rest[1:]
+				}
+				value.WriteByte(c)
+			}
+
+			labels = append(labels, name+"="+value.String())
+			if rest[0] == ',' {
+				rest = rest[1:]
+			}
+		}
+		rest = rest[1:]
+	}
+
+	if len(rest) < 2 || rest[0] != ' ' {
+		return "", "", "", fmt.Errorf("missing value: %q", line)
+	}
+
+	return metric, strings.Join(labels, ","), rest[1:], nil
+}
+
+func TestHandlerShouldWriteOutcomes(t *testing.T) {
+	a := breaker.NewBreakerWithDefault(breaker.MetricsOptions{Name: "a", MetricsRollingCount: 1})
+	b := breaker.NewBreakerWithDefault(breaker.MetricsOptions{Name: "b", MetricsRollingCount: 1})
+	defer a.Stop()
+	defer b.Stop()
+
+	for _, outcome := range []breaker.Outcome{breaker.SUCCEED, breaker.SUCCEED, breaker.FAILED, breaker.TIMEOUT} {
+		done, _ := a.Allow()
+		done(outcome)
+	}
+
+	b.ForceOpen()
+	b.Allow()
+	b.Allow()
+
+	s := httptest.NewServer(HandlerOf(a, b))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL)
+	if err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != ContentType {
+		t.Fatalf("content type should be %q, got: %q", ContentType, ct)
+	}
+
+	res, err := parse(resp.Body)
+	if err != nil {
+		t.Fatalf("output should parse, got: %v", err)
+	}
+
+	cases := []struct {
+		metric, kind, labels, value string
+	}{
+		{"breaker_state", "gauge", "name=a,state=closed", "1"},
+		{"breaker_state", "gauge", "name=a,state=open", "0"},
+		{"breaker_state", "gauge", "name=b,state=half-open", "0"},
+		{"breaker_state", "gauge", "name=b,state=forced-open", "1"},
+		{"breaker_outcomes_total", "counter", "name=a,outcome=succeed", "2"},
+		{"breaker_outcomes_total", "counter", "name=a,outcome=failed", "1"},
+		{"breaker_outcomes_total", "counter", "name=a,outcome=timeout", "1"},
+		{"breaker_outcomes_total", "counter", "name=a,outcome=reject", "0"},
+		{"breaker_outcomes_total", "counter", "name=b,outcome=succeed", "0"},
+		{"breaker_slow_calls_total", "counter", "name=b", "0"},
+		{"breaker_short_circuited_total", "counter", "name=a", "0"},
+		{"breaker_short_circuited_total", "counter", "name=b", "2"},
+		{"breaker_outcomes_total", "counter", "name=b,outcome=reject", "0"},
+	}
+
+	for _, c := range cases {
+		f := res[c.metric]
+		if f == nil || f.kind != c.kind {
+			t.Fatalf("%s should be a %s, got: %+v", c.metric, c.kind, f)
+		}
+		if v := f.samples[c.labels]; v != c.value {
+			t.Fatalf("%s{%s} should be %s, got: %q", c.metric, c.labels, c.value, v)
+		}
+	}
+
+	if n := len(res["breaker_state"].samples); n != 14 {
+		t.Fatalf("every state of every breaker should be written, got: %d", n)
+	}
+}
+
+func TestWriteSnapshotsShouldWriteTransitionsAndEscapeNames(t *testing.T) {
+	snapshots := []breaker.Snapshot{{
+		Name:  "a \"b\"\\c\nd",
+		State: breaker.OPEN,
+		Totals: breaker.Totals{
+			Transitions: map[breaker.StateChange]uint64{
+				{From: breaker.CLOSED, To: breaker.OPEN}:     3,
+				{From: breaker.OPEN, To: breaker.HALFOPEN}:   2,
+				{From: breaker.HALFOPEN, To: breaker.CLOSED}: 1,
+			},
+		},
+	}}
+
+	var buf strings.Builder
+	if err := WriteSnapshots(&buf, snapshots); err != nil {
+		t.Fatalf("err should be nil, got: %v", err)
+	}
+
+	res, err := parse(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("output should parse, got: %v\n%s", err, buf.String())
+	}
+
+	name := "name=" + snapshots[0].Name
+
+	if v := res["breaker_state"].samples[name+",state=open"]; v != "1" {
+		t.Fatalf("state should be open, got: %q", v)
+	}
+
+	transitions := res["breaker_transitions_total"]
+	if transitions.kind != "counter" || len(transitions.samples) != 3 {
+		t.Fatalf("transitions should be 3 counters, got: %+v", transitions)
+	}
+	if v := transitions.samples[name+",from=closed,to=open"]; v != "3" {
+		t.Fatalf("closed to open should be 3, got: %q", v)
+	}
+	if v := transitions.samples[name+",from=open,to=half-open"]; v != "2" {
+		t.Fatalf("open to half-open should be 2, got: %q", v)
+	}
+}
@@ -0,0 +1,55 @@
+package breaker
+
+import (
+	"math"
+	"time"
+)
+
+type Ramp uint8
+
+const (
+	// Admits a fraction of the calls growing with the time spent in RAMPUP.
+	LINEARRAMP Ramp = iota
+
+	// Admits a fraction of the calls doubling every tenth of the interval,
+	// from about 0.1% up to all of them.
+	EXPONENTIALRAMP
+)
+
+var rampNames = []string{
+	LINEARRAMP:      "linear",
+	EXPONENTIALRAMP: "exponential",
+}
+
+func (r Ramp) String() string {
+	return name(rampNames, uint8(r))
+}
+
+func (r Ramp) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Ramp) UnmarshalText(text []byte) error {
+	res, err := parse(rampNames, "ramp", text)
+	if err == nil {
+		*r = Ramp(res)
+	}
+
+	return err
+}
+
+// fraction returns the share of calls admitted after elapsed out of the
+// ramp-up interval.
+func (r Ramp) fraction(elapsed, interval time.Duration) float64 {
+	if interval <= 0 || elapsed >= interval {
+		return 1
+	}
+
+	x := float64(elapsed) / float64(interval)
+
+	if r == EXPONENTIALRAMP {
+		return math.Exp2(10 * (x - 1))
+	}
+
+	return x
+}
@@ -0,0 +1,137 @@
+package breaker
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRampMethodFraction(t *testing.T) {
+	cases := []struct {
+		ramp    Ramp
+		elapsed time.Duration
+		res     float64
+	}{
+		{LINEARRAMP, 0, 0},
+		{LINEARRAMP, 25 * time.Second, 0.25},
+		{LINEARRAMP, 100 * time.Second, 1},
+		{LINEARRAMP, time.Hour, 1},
+		{EXPONENTIALRAMP, 0, 1.0 / 1024},
+		{EXPONENTIALRAMP, 90 * time.Second, 0.5},
+		{EXPONENTIALRAMP, 100 * time.Second, 1},
+	}
+
+	for _, c := range cases {
+		if res := c.ramp.fraction(c.elapsed, 100*time.Second); math.Abs(res-c.res) > 1e-9 {
+			t.Fatalf("%s fraction after %v should be %v, got: %v", c.ramp, c.elapsed, c.res, res)
+		}
+	}
+
+	if res := LINEARRAMP.fraction(0, 0); res != 1 {
+		t.Fatalf("fraction without interval should be 1, got: %v", res)
+	}
+}
+
+func TestBreakerShouldRampUpAfterRecovery(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, RecoverInterval: time.Minute, RampUpInterval: time.Minute}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.changeState(CLOSED)
+
+	if b.State() != RAMPUP {
+		t.Fatalf("state should be RAMPUP after recovery, got: %s", b.State())
+	}
+
+	res := b.Snapshot()
+	if res.RampUntil.Sub(res.Since) != time.Minute || res.Admitted > 0.01 {
+		t.Fatalf("ramp up should last a minute from nothing, got: %v, %v", res.RampUntil, res.Admitted)
+	}
+
+	var admitted int
+	for i := 0; i < 100; i++ {
+		if done, err := b.Allow(); err == nil {
+			admitted++
+			done(SUCCEED)
+		}
+	}
+	if admitted > 10 {
+		t.Fatalf("few calls should be admitted at the start of the ramp, got: %d", admitted)
+	}
+
+	b.cur.Load().since = time.Now().Add(-30 * time.Second)
+	admitted = 0
+	for i := 0; i < 1000; i++ {
+		if done, err := b.Allow(); err == nil {
+			admitted++
+			done(SUCCEED)
+		}
+	}
+	if admitted < 400 || admitted > 600 {
+		t.Fatalf("half of the calls should be admitted halfway, got: %d", admitted)
+	}
+
+	if res := b.Snapshot(); res.Recovery.ShortCircuited == 0 || res.Recovery.Succeeded < uint32(admitted) {
+		t.Fatalf("rejected calls should be counted, got: %+v", res.Recovery)
+	}
+
+	b.run(b.rampUntil)
+
+	if b.State() != CLOSED {
+		t.Fatalf("state should be CLOSED once ramped up, got: %s", b.State())
+	}
+}
+
+func TestBreakerShouldReopenWhenTrippedDuringRampUp(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, UpdateStateInterval: time.Second, RecoverInterval: time.Minute, RampUpInterval: time.Minute, RecoverBackoff: 2}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.changeState(CLOSED)
+
+	b.record(b.current(), FAILED, 0)
+	b.record(b.current(), FAILED, 0)
+	b.record(b.current(), SUCCEED, 0)
+	b.nextUpdate = time.Now()
+	b.nextFlush = b.nextUpdate
+	b.run(b.nextUpdate)
+
+	if b.State() != OPEN {
+		t.Fatalf("state should be OPEN when the policy trips during ramp up, got: %s", b.State())
+	}
+	if b.openFor != 2*time.Minute {
+		t.Fatalf("reopening during ramp up should back off, got: %v", b.openFor)
+	}
+}
+
+func TestBreakerMethodRunAfterFailedRampUp(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, RecoverInterval: time.Minute, RecoverBackoff: 2, RampUpInterval: time.Minute}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.changeState(CLOSED)
+	b.changeState(OPEN)
+
+	if b.rampUntil.IsZero() == false {
+		t.Fatalf("ramp up deadline should be cleared when reopened, got: %v", b.rampUntil)
+	}
+
+	now := time.Now().Add(90 * time.Second)
+	if next := b.run(now); next.IsZero() == false && next.Before(now) {
+		t.Fatalf("next run should not be in the past, got: %v", now.Sub(next))
+	}
+	if b.State() != OPEN {
+		t.Fatalf("state should stay OPEN until the backed off recovery, got: %s", b.State())
+	}
+
+	now = b.recoverAt
+	if next := b.run(now); next.IsZero() == false && next.Before(now) {
+		t.Fatalf("next run should not be in the past, got: %v", now.Sub(next))
+	}
+	if b.State() != HALFOPEN {
+		t.Fatalf("state should be HALFOPEN after recovery, got: %s", b.State())
+	}
+}
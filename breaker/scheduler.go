@@ -0,0 +1,182 @@
+package breaker
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// scheduler drives the periodic work of every started breaker from a single
+// goroutine, breakers being kept in a heap ordered by their next due time.
+// The goroutine exits when no breaker is left and is restarted on demand.
+type scheduler struct {
+	sync.Mutex
+
+	breakers schedule
+	wake     chan struct{}
+	running  bool
+}
+
+var defaultScheduler = newScheduler()
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+type schedule []*Breaker
+
+func (s schedule) Len() int {
+	return len(s)
+}
+
+func (s schedule) Less(i, j int) bool {
+	return s[i].due.Before(s[j].due)
+}
+
+func (s schedule) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+	s[i].index = i
+	s[j].index = j
+}
+
+func (s *schedule) Push(x interface{}) {
+	b := x.(*Breaker)
+	b.index = len(*s)
+	*s = append(*s, b)
+}
+
+func (s *schedule) Pop() interface{} {
+	old := *s
+	n := len(old)
+	b := old[n-1]
+	old[n-1] = nil
+	b.index = -1
+	*s = old[:n-1]
+	return b
+}
+
+func (s *scheduler) register(b *Breaker, due time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	if b.scheduled {
+		return
+	}
+
+	b.scheduled = true
+	b.index = -1
+	s.push(b, due)
+}
+
+func (s *scheduler) unregister(b *Breaker) {
+	s.Lock()
+	defer s.Unlock()
+
+	if b.scheduled == false {
+		return
+	}
+
+	b.scheduled = false
+	if b.index >= 0 {
+		heap.Remove(&s.breakers, b.index)
+	}
+}
+
+// reschedule brings the next run of b forward to at.
+func (s *scheduler) reschedule(b *Breaker, at time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	switch {
+	case b.scheduled == false:
+	case b.running:
+		if b.pending.IsZero() || at.Before(b.pending) {
+			b.pending = at
+		}
+	case b.index < 0:
+		s.push(b, at)
+	case at.Before(b.due):
+		b.due = at
+		heap.Fix(&s.breakers, b.index)
+		if b.index == 0 {
+			s.notify()
+		}
+	}
+}
+
+func (s *scheduler) push(b *Breaker, due time.Time) {
+	if due.IsZero() {
+		return
+	}
+
+	b.due = due
+	heap.Push(&s.breakers, b)
+
+	if s.running == false {
+		s.running = true
+		go s.loop()
+	} else if b.index == 0 {
+		s.notify()
+	}
+}
+
+func (s *scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) loop() {
+	t := time.NewTimer(time.Hour)
+	defer t.Stop()
+
+	for {
+		s.Lock()
+		if len(s.breakers) == 0 {
+			s.running = false
+			s.Unlock()
+			return
+		}
+
+		b := s.breakers[0]
+		now := time.Now()
+
+		if d := b.due.Sub(now); d > 0 {
+			s.Unlock()
+
+			if t.Stop() == false {
+				select {
+				case <-t.C:
+				default:
+				}
+			}
+			t.Reset(d)
+
+			select {
+			case <-t.C:
+			case <-s.wake:
+			}
+			continue
+		}
+
+		heap.Pop(&s.breakers)
+		b.running = true
+		s.Unlock()
+
+		due := b.run(now)
+
+		s.Lock()
+		b.running = false
+		if pending := b.pending; pending.IsZero() == false && (due.IsZero() || pending.Before(due)) {
+			due = pending
+		}
+		b.pending = time.Time{}
+		if b.scheduled {
+			s.push(b, due)
+		}
+		s.Unlock()
+	}
+}
@@ -0,0 +1,110 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSchedulerMethodRegisterKeepEarliestFirst(t *testing.T) {
+	s := newScheduler()
+	now := time.Now()
+
+	breakers := make([]*Breaker, 3)
+	for i := range breakers {
+		breakers[i] = newBreaker(MetricsOptions{}, DefaultPolicy)
+		breakers[i].sched = s
+	}
+
+	s.register(breakers[0], now.Add(3*time.Hour))
+	s.register(breakers[1], now.Add(1*time.Hour))
+	s.register(breakers[2], now.Add(2*time.Hour))
+	s.register(breakers[2], now.Add(time.Minute))
+
+	s.Lock()
+	if len(s.breakers) != 3 || s.breakers[0] != breakers[1] {
+		t.Fatalf("earliest breaker should be first, got: %d breakers", len(s.breakers))
+	}
+	s.Unlock()
+
+	s.reschedule(breakers[0], now.Add(time.Hour/2))
+
+	s.Lock()
+	if s.breakers[0] != breakers[0] {
+		t.Fatalf("rescheduled breaker should be first")
+	}
+	s.Unlock()
+
+	s.reschedule(breakers[0], now.Add(4*time.Hour))
+
+	s.Lock()
+	if s.breakers[0] != breakers[0] {
+		t.Fatalf("reschedule should not postpone a breaker")
+	}
+	s.Unlock()
+
+	for _, b := range breakers {
+		s.unregister(b)
+	}
+
+	s.Lock()
+	if len(s.breakers) != 0 {
+		t.Fatalf("all breakers should be unregistered, got: %d", len(s.breakers))
+	}
+	s.Unlock()
+}
+
+func TestSchedulerMethodRegisterShouldIgnoreZeroDueTime(t *testing.T) {
+	s := newScheduler()
+	b := newBreaker(MetricsOptions{}, DefaultPolicy)
+	b.sched = s
+
+	s.register(b, time.Time{})
+
+	s.Lock()
+	if b.scheduled == false || len(s.breakers) != 0 || s.running == true {
+		t.Fatalf("breaker should be registered but idle")
+	}
+	s.Unlock()
+
+	s.reschedule(b, time.Now().Add(time.Hour))
+
+	s.Lock()
+	if len(s.breakers) != 1 || s.running == false {
+		t.Fatalf("rescheduled idle breaker should be pushed")
+	}
+	s.Unlock()
+
+	s.unregister(b)
+}
+
+func TestSchedulerMethodLoopDriveRecovery(t *testing.T) {
+	s := newScheduler()
+	b := newBreaker(MetricsOptions{RecoverInterval: time.Millisecond}, DefaultPolicy)
+	b.sched = s
+
+	transitions := make(chan Transition, 2)
+	b.Subscribe(func(t Transition) { transitions <- t })
+
+	b.Start()
+	b.changeState(OPEN)
+
+	<-transitions
+	if res := <-transitions; res.To != HALFOPEN || res.Reason != REASONRECOVERYTIMER {
+		t.Fatalf("breaker should recover to HALFOPEN, got: %+v", res)
+	}
+
+	b.Stop()
+
+	for i := 0; i < 100; i++ {
+		s.Lock()
+		running := s.running
+		s.Unlock()
+
+		if running == false {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	t.Fatalf("scheduler should exit when no breaker is left")
+}
@@ -0,0 +1,150 @@
+package breaker
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is a consistent copy of the breaker's metrics.
+type Snapshot struct {
+	Name  string
+	State State
+
+	// When the current state was entered and for how long it has lasted.
+	Since   time.Time
+	InState time.Duration
+
+	// Counts of the rolling window, oldest bucket first, and their sum.
+	Buckets []Counts
+	Total   Counts
+
+	// Failures over all calls of the rolling window, in percent.
+	ErrorPercent float64
+
+	// Trial calls in flight and their limit, and the outcomes of those
+	// completed while HALFOPEN or RAMPUP.
+	Probes    uint32
+	MaxProbes uint32
+	Recovery  Counts
+
+	// When the breaker moves to HALFOPEN, zero unless OPEN.
+	RecoverAt time.Time
+
+	// When the breaker moves to CLOSED and the fraction of calls admitted
+	// until then, zero unless RAMPUP.
+	RampUntil time.Time
+	Admitted  float64
+
+	// Probability of rejecting a call locally, see ThrottleK.
+	Throttle float64
+
+	// How long the breaker stays OPEN after the last trip, backed off on
+	// failed recoveries, zero once CLOSED.
+	OpenFor time.Duration
+
+	// Latency of the calls completed within the rolling window.
+	Latency Latency
+
+	// Outcomes recorded and state changes made since the breaker was
+	// created.
+	Totals Totals
+}
+
+type StateChange struct {
+	From State
+	To   State
+}
+
+type Totals struct {
+	Succeeded uint64
+	Failed    uint64
+	TimedOut  uint64
+	Rejected  uint64
+	Slow      uint64
+
+	ShortCircuited uint64
+
+	Transitions map[StateChange]uint64
+}
+
+func (t *Totals) add(c Counts) {
+	t.Succeeded += uint64(c.Succeeded)
+	t.Failed += uint64(c.Failed)
+	t.TimedOut += uint64(c.TimedOut)
+	t.Rejected += uint64(c.Rejected)
+	t.Slow += uint64(c.Slow)
+	t.ShortCircuited += uint64(c.ShortCircuited)
+}
+
+func (t *Totals) change(from, to State) {
+	if t.Transitions == nil {
+		t.Transitions = make(map[StateChange]uint64)
+	}
+
+	t.Transitions[StateChange{From: from, To: to}]++
+}
+
+func (t Totals) clone() Totals {
+	transitions := make(map[StateChange]uint64, len(t.Transitions))
+	for k, v := range t.Transitions {
+		transitions[k] = v
+	}
+
+	t.Transitions = transitions
+	return t
+}
+
+// Snapshot is taken under the breaker lock and can be called from any
+// goroutine.
+func (b *Breaker) Snapshot() Snapshot {
+	now := time.Now()
+
+	b.Lock()
+	defer b.Unlock()
+
+	b.flush()
+
+	e := b.current()
+	res := Snapshot{
+		Name:      b.Name,
+		State:     e.state,
+		Since:     e.since,
+		InState:   now.Sub(e.since),
+		Buckets:   make([]Counts, len(b.buckets)),
+		MaxProbes: b.MaxHalfOpenRequests,
+		RecoverAt: b.recoverAt,
+		OpenFor:   b.openFor,
+	}
+
+	switch e.state {
+	case CLOSED:
+		res.Throttle = math.Float64frombits(b.throttle.Load())
+	case HALFOPEN:
+		res.Probes = uint32(atomic.LoadInt32(&e.probes))
+		res.Recovery = b.recoveryBucket.counts()
+	case RAMPUP:
+		res.Recovery = b.recoveryBucket.counts()
+		res.RampUntil = b.rampUntil
+		res.Admitted = b.RampUp.fraction(res.InState, b.RampUpInterval)
+	}
+
+	var window histogram
+	for i := range b.buckets {
+		res.Buckets[i] = b.buckets[i].counts()
+		res.Total = res.Total.Add(res.Buckets[i])
+
+		if h := b.buckets[i].latency; h != nil {
+			window.merge(h)
+		}
+	}
+
+	if total := res.Total.Total(); total > 0 {
+		res.ErrorPercent = float64(res.Total.Failures()) * 100 / float64(total)
+	}
+
+	res.Latency = window.latency()
+	res.Totals = b.totals.clone()
+
+	return res
+}
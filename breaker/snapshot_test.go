@@ -0,0 +1,173 @@
+package breaker
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBreakerMethodSnapshotCounts(t *testing.T) {
+	b := newBreaker(MetricsOptions{Name: "test", MetricsRollingCount: 2}, DefaultPolicy)
+
+	e := b.current()
+	b.record(e, SUCCEED, 0)
+	b.record(e, FAILED, 0)
+
+	b.Lock()
+	b.rotate()
+	b.Unlock()
+
+	b.record(e, SUCCEED, 0)
+	b.record(e, TIMEOUT, 0)
+
+	res := b.Snapshot()
+	if res.Name != "test" || res.State != CLOSED {
+		t.Fatalf("snapshot should be of CLOSED test, got: %q, %v", res.Name, res.State)
+	}
+	if len(res.Buckets) != 2 || res.Buckets[0] != (Counts{Succeeded: 1, Failed: 1}) || res.Buckets[1] != (Counts{Succeeded: 1, TimedOut: 1}) {
+		t.Fatalf("buckets should be ordered oldest first, got: %+v", res.Buckets)
+	}
+	if res.Total != (Counts{Succeeded: 2, Failed: 1, TimedOut: 1}) {
+		t.Fatalf("total should add up buckets, got: %+v", res.Total)
+	}
+	if res.ErrorPercent != 50 {
+		t.Fatalf("error percent should be 50, got: %v", res.ErrorPercent)
+	}
+	if res.Since != e.since || res.InState < 0 {
+		t.Fatalf("since should be the start of the state, got: %v, %v", res.Since, res.InState)
+	}
+	if res.RecoverAt.IsZero() == false {
+		t.Fatalf("recover at should be zero when CLOSED, got: %v", res.RecoverAt)
+	}
+}
+
+func TestBreakerMethodSnapshotOpenAndHalfOpen(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, RecoverInterval: time.Minute, MaxHalfOpenRequests: 2}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	b.changeState(OPEN)
+
+	res := b.Snapshot()
+	if res.State != OPEN || res.RecoverAt.IsZero() || res.RecoverAt.Sub(res.Since) < time.Minute {
+		t.Fatalf("recover at should be a minute after tripping, got: %v, %v", res.State, res.RecoverAt)
+	}
+
+	b.changeState(HALFOPEN)
+
+	done, _ := b.Allow()
+	b.Allow()
+	done(SUCCEED)
+
+	res = b.Snapshot()
+	if res.State != HALFOPEN || res.Probes != 1 || res.MaxProbes != 2 {
+		t.Fatalf("one probe out of 2 should be in flight, got: %v, %d/%d", res.State, res.Probes, res.MaxProbes)
+	}
+	if res.Recovery != (Counts{Succeeded: 1}) {
+		t.Fatalf("recovery should count completed probes, got: %+v", res.Recovery)
+	}
+	if res.RecoverAt.IsZero() == false {
+		t.Fatalf("recover at should be zero when HALFOPEN, got: %v", res.RecoverAt)
+	}
+}
+
+func TestBreakerMethodSnapshotShouldBeSafeForConcurrentUse(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 4}, DefaultPolicy)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				done, _ := b.Allow()
+				done(SUCCEED)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.Snapshot()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if res := b.Snapshot(); res.Total.Succeeded != 4000 {
+		t.Fatalf("all outcomes should be counted, got: %+v", res.Total)
+	}
+}
+
+func TestBreakerMethodSnapshotLatency(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 2}, DefaultPolicy)
+
+	e := b.current()
+	b.record(e, SUCCEED, 10*time.Millisecond)
+	b.record(e, FAILED, 20*time.Millisecond)
+
+	b.Lock()
+	b.rotate()
+	b.Unlock()
+
+	b.record(e, SUCCEED, 30*time.Millisecond)
+	b.record(e, SUCCEED, 0)
+
+	res := b.Snapshot().Latency
+	if res.Count != 3 {
+		t.Fatalf("count should be 3, got: %d", res.Count)
+	}
+	if res.Max != 30*time.Millisecond {
+		t.Fatalf("max should be 30ms, got: %v", res.Max)
+	}
+	if res.P50 < 20*time.Millisecond || res.P50 > 23*time.Millisecond {
+		t.Fatalf("p50 should be about 20ms, got: %v", res.P50)
+	}
+
+	b.Lock()
+	b.rotate()
+	b.rotate()
+	b.Unlock()
+
+	if res := b.Snapshot().Latency; res.Count != 0 {
+		t.Fatalf("latency should leave the window with its bucket, got: %+v", res)
+	}
+}
+
+func TestBreakerMethodSnapshotTotals(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, RecoverInterval: time.Minute}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	b.record(b.current(), SUCCEED, 0)
+	b.record(b.current(), FAILED, time.Millisecond)
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.record(b.current(), TIMEOUT, 0)
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+	b.changeState(CLOSED)
+	b.record(b.current(), REJECT, 0)
+
+	res := b.Snapshot().Totals
+	if res.Succeeded != 1 || res.Failed != 1 || res.TimedOut != 1 || res.Rejected != 1 {
+		t.Fatalf("totals should count outcomes across states, got: %+v", res)
+	}
+
+	want := map[StateChange]uint64{
+		{CLOSED, OPEN}:     1,
+		{OPEN, HALFOPEN}:   2,
+		{HALFOPEN, OPEN}:   1,
+		{HALFOPEN, CLOSED}: 1,
+	}
+	if len(res.Transitions) != len(want) {
+		t.Fatalf("transitions should be %v, got: %v", want, res.Transitions)
+	}
+	for k, v := range want {
+		if res.Transitions[k] != v {
+			t.Fatalf("transitions should be %v, got: %v", want, res.Transitions)
+		}
+	}
+
+	res.Transitions[StateChange{CLOSED, OPEN}] = 10
+	if n := b.Snapshot().Totals.Transitions[StateChange{CLOSED, OPEN}]; n != 1 {
+		t.Fatalf("snapshot should not share transitions with the breaker, got: %d", n)
+	}
+}
@@ -0,0 +1,128 @@
+package breaker
+
+import (
+	"fmt"
+)
+
+type Outcome uint8
+
+type State uint8
+
+type Decision uint8
+
+const (
+	SUCCEED Outcome = 0
+	FAILED  Outcome = 1
+	TIMEOUT Outcome = 2
+	REJECT  Outcome = 3
+
+	CLOSED   State = 0
+	OPEN     State = 1
+	HALFOPEN State = 2
+	RAMPUP   State = 3
+
+	FORCEDOPEN   State = 4
+	FORCEDCLOSED State = 5
+	DISABLED     State = 6
+
+	HOLD  Decision = 0
+	TRUE  Decision = 1
+	FALSE Decision = 2
+)
+
+var outcomeNames = []string{
+	SUCCEED: "succeed",
+	FAILED:  "failed",
+	TIMEOUT: "timeout",
+	REJECT:  "reject",
+}
+
+var stateNames = []string{
+	CLOSED:   "closed",
+	OPEN:     "open",
+	HALFOPEN: "half-open",
+	RAMPUP:   "ramp-up",
+
+	FORCEDOPEN:   "forced-open",
+	FORCEDCLOSED: "forced-closed",
+	DISABLED:     "disabled",
+}
+
+var decisionNames = []string{
+	HOLD:  "hold",
+	TRUE:  "true",
+	FALSE: "false",
+}
+
+func name(names []string, i uint8) string {
+	if int(i) < len(names) {
+		return names[i]
+	}
+
+	return fmt.Sprintf("unknown(%d)", i)
+}
+
+func parse(names []string, kind string, text []byte) (uint8, error) {
+	for i, name := range names {
+		if name == string(text) {
+			return uint8(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("breaker: unknown %s %q", kind, text)
+}
+
+func (o Outcome) String() string {
+	return name(outcomeNames, uint8(o))
+}
+
+func (o Outcome) MarshalText() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+func (o *Outcome) UnmarshalText(text []byte) error {
+	res, err := parse(outcomeNames, "outcome", text)
+	if err == nil {
+		*o = Outcome(res)
+	}
+
+	return err
+}
+
+func (s State) String() string {
+	return name(stateNames, uint8(s))
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	res, err := parse(stateNames, "state", text)
+	if err == nil {
+		*s = State(res)
+	}
+
+	return err
+}
+
+func (d Decision) String() string {
+	return name(decisionNames, uint8(d))
+}
+
+func (d Decision) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Decision) UnmarshalText(text []byte) error {
+	res, err := parse(decisionNames, "decision", text)
+	if err == nil {
+		*d = Decision(res)
+	}
+
+	return err
+}
+
+func (b *Breaker) State() State {
+	return b.current().state
+}
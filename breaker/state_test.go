@@ -0,0 +1,85 @@
+package breaker
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestStateString(t *testing.T) {
+	cases := map[State]string{
+		CLOSED:   "closed",
+		OPEN:     "open",
+		HALFOPEN: "half-open",
+		RAMPUP:   "ramp-up",
+		DISABLED: "disabled",
+		State(9): "unknown(9)",
+	}
+
+	for s, res := range cases {
+		if s.String() != res {
+			t.Fatalf("result should be %s, got: %s", res, s)
+		}
+	}
+}
+
+func TestOutcomeAndDecisionString(t *testing.T) {
+	if TIMEOUT.String() != "timeout" {
+		t.Fatalf("result should be timeout, got: %s", TIMEOUT)
+	}
+	if FALSE.String() != "false" {
+		t.Fatalf("result should be false, got: %s", FALSE)
+	}
+}
+
+func TestStateMarshalText(t *testing.T) {
+	res, err := json.Marshal(map[string]State{"state": HALFOPEN})
+	if err != nil || string(res) != `{"state":"half-open"}` {
+		t.Fatalf("result should be half-open, got: %s, %v", res, err)
+	}
+
+	var s State
+	if err := s.UnmarshalText([]byte("open")); err != nil || s != OPEN {
+		t.Fatalf("result should be open, got: %s, %v", s, err)
+	}
+
+	if err := s.UnmarshalText([]byte("test")); err == nil || s != OPEN {
+		t.Fatalf("unknown state should return error and keep value, got: %s, %v", s, err)
+	}
+}
+
+func TestOutcomeAndDecisionUnmarshalText(t *testing.T) {
+	var outcome Outcome
+	if err := outcome.UnmarshalText([]byte("reject")); err != nil || outcome != REJECT {
+		t.Fatalf("result should be reject, got: %s, %v", outcome, err)
+	}
+	if err := outcome.UnmarshalText([]byte("open")); err == nil {
+		t.Fatalf("unknown outcome should return error")
+	}
+
+	var decision Decision
+	if err := decision.UnmarshalText([]byte("true")); err != nil || decision != TRUE {
+		t.Fatalf("result should be true, got: %s, %v", decision, err)
+	}
+
+	res, err := decision.MarshalText()
+	if err != nil || string(res) != "true" {
+		t.Fatalf("result should be true, got: %s, %v", res, err)
+	}
+
+	res, err = outcome.MarshalText()
+	if err != nil || string(res) != "reject" {
+		t.Fatalf("result should be reject, got: %s, %v", res, err)
+	}
+}
+
+func TestBreakerMethodState(t *testing.T) {
+	b := newBreakerInState(HALFOPEN, MetricsOptions{})
+
+	if b.State() != HALFOPEN {
+		t.Fatalf("state should be HALFOPEN, got: %s", b.State())
+	}
+
+	if (&Breaker{}).State() != CLOSED {
+		t.Fatalf("zero breaker should be CLOSED")
+	}
+}
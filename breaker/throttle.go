@@ -0,0 +1,36 @@
+package breaker
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// updateThrottle computes the probability of rejecting a call from the
+// rolling window, following the client-side throttling of the SRE book. It
+// must be called with the lock held.
+func (b *Breaker) updateThrottle() {
+	if b.ThrottleK <= 0 {
+		return
+	}
+
+	var requests, accepts float64
+	for i := range b.buckets {
+		requests += float64(b.buckets[i].all())
+		accepts += float64(b.buckets[i].succeed)
+	}
+
+	p := math.Max(0, (requests-b.ThrottleK*accepts)/(requests+1))
+	b.throttle.Store(math.Float64bits(p))
+}
+
+// throttled rejects a call with the current probability, the rejection
+// being recorded as REJECT so that it counts as a request not accepted.
+func (b *Breaker) throttled(e *epoch) bool {
+	p := math.Float64frombits(b.throttle.Load())
+	if p <= 0 || rand.Float64() >= p {
+		return false
+	}
+
+	e.add(REJECT)
+	return true
+}
@@ -0,0 +1,81 @@
+package breaker
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBreakerMethodUpdateThrottle(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 2, ThrottleK: 2}, DefaultPolicy)
+
+	cases := []struct {
+		buckets []bucket
+		res     float64
+	}{
+		{[]bucket{{}, {}}, 0},
+		{[]bucket{{succeed: 10}, {succeed: 10}}, 0},
+		{[]bucket{{succeed: 5, failed: 5}, {}}, 0},
+		{[]bucket{{succeed: 10, failed: 40}, {failed: 49, reject: 1}}, 80.0 / 101},
+	}
+
+	for _, c := range cases {
+		copy(b.buckets, c.buckets)
+		b.updateThrottle()
+
+		if res := math.Float64frombits(b.throttle.Load()); math.Abs(res-c.res) > 1e-9 {
+			t.Fatalf("throttle of %+v should be %v, got: %v", c.buckets, c.res, res)
+		}
+	}
+}
+
+func TestBreakerShouldThrottleInsteadOfTripping(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1, UpdateStateInterval: time.Second, ThrottleK: 1}, DefaultPolicy)
+	b.sched = newScheduler()
+
+	for i := 0; i < 100; i++ {
+		b.record(b.current(), FAILED, 0)
+	}
+
+	now := time.Now()
+	b.nextFlush = now
+	b.nextUpdate = now
+	b.run(now)
+
+	if b.State() != CLOSED {
+		t.Fatalf("breaker should not trip when throttling, got: %s", b.State())
+	}
+
+	res := b.Snapshot()
+	if math.Abs(res.Throttle-100.0/101) > 1e-9 {
+		t.Fatalf("throttle should be 100/101, got: %v", res.Throttle)
+	}
+
+	var rejected int
+	for i := 0; i < 1000; i++ {
+		done, err := b.Allow()
+		if err == ErrOpen {
+			rejected++
+			continue
+		}
+		done(SUCCEED)
+	}
+
+	if rejected < 950 {
+		t.Fatalf("most calls should be rejected, got: %d", rejected)
+	}
+	if res := b.current().drain(); res.reject != uint32(rejected) {
+		t.Fatalf("rejections should be recorded as REJECT, got: %+v", res)
+	}
+}
+
+func TestBreakerShouldNotThrottleWithoutK(t *testing.T) {
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 1}, DefaultPolicy)
+
+	b.buckets[0] = bucket{failed: 100}
+	b.updateThrottle()
+
+	if _, err := b.Allow(); err != nil {
+		t.Fatalf("calls should not be throttled without K, got: %v", err)
+	}
+}
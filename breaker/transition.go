@@ -0,0 +1,110 @@
+package breaker
+
+import (
+	"sync"
+	"time"
+)
+
+type Reason uint8
+
+const (
+	REASONTRIP Reason = iota
+	REASONRECOVERYTIMER
+	REASONPROBESUCCEED
+	REASONPROBEFAILED
+	REASONMANUAL
+	REASONRAMPEDUP
+)
+
+var reasonNames = []string{
+	REASONTRIP:          "trip",
+	REASONRECOVERYTIMER: "recovery timer",
+	REASONPROBESUCCEED:  "half-open success",
+	REASONPROBEFAILED:   "half-open failure",
+	REASONMANUAL:        "manual",
+	REASONRAMPEDUP:      "ramped up",
+}
+
+func (r Reason) String() string {
+	return name(reasonNames, uint8(r))
+}
+
+func reasonOf(from, to State) Reason {
+	switch {
+	case from == CLOSED, from == RAMPUP && to == OPEN:
+		return REASONTRIP
+	case from == OPEN:
+		return REASONRECOVERYTIMER
+	case from == RAMPUP:
+		return REASONRAMPEDUP
+	case to == CLOSED, to == RAMPUP:
+		return REASONPROBESUCCEED
+	}
+
+	return REASONPROBEFAILED
+}
+
+// Transition describes a state change. Counts holds the rolling buckets
+// when tripped from CLOSED, the recovery bucket when leaving HALFOPEN or
+// RAMPUP.
+type Transition struct {
+	Name   string
+	From   State
+	To     State
+	At     time.Time
+	Reason Reason
+	Counts []Counts
+}
+
+type notifier struct {
+	sync.Mutex
+
+	hooks   []func(Transition)
+	queue   []Transition
+	running bool
+}
+
+// Subscribe registers fn to be called, in order and outside the breaker
+// lock, on every state change.
+func (b *Breaker) Subscribe(fn func(Transition)) {
+	b.events.Lock()
+	defer b.events.Unlock()
+
+	b.events.hooks = append(b.events.hooks[:len(b.events.hooks):len(b.events.hooks)], fn)
+}
+
+func (b *Breaker) publish(t Transition) {
+	b.events.Lock()
+	defer b.events.Unlock()
+
+	b.events.queue = append(b.events.queue, t)
+
+	if b.events.running == false {
+		b.events.running = true
+		go b.notify()
+	}
+}
+
+func (b *Breaker) notify() {
+	for {
+		b.events.Lock()
+		if len(b.events.queue) == 0 {
+			b.events.running = false
+			b.events.Unlock()
+			return
+		}
+
+		t := b.events.queue[0]
+		b.events.queue = b.events.queue[1:]
+		hooks := b.events.hooks
+		b.events.Unlock()
+
+		if b.OnStateChange != nil {
+			b.OnStateChange(t)
+		}
+
+		for _, hook := range hooks {
+			hook(t)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReasonString(t *testing.T) {
+	if REASONTRIP.String() != "trip" {
+		t.Fatalf("result should be trip, got: %s", REASONTRIP)
+	}
+	if Reason(100).String() != "unknown(100)" {
+		t.Fatalf("result should be unknown(100), got: %s", Reason(100))
+	}
+}
+
+func TestReasonOf(t *testing.T) {
+	cases := []struct {
+		from, to State
+		reason   Reason
+	}{
+		{CLOSED, OPEN, REASONTRIP},
+		{OPEN, HALFOPEN, REASONRECOVERYTIMER},
+		{HALFOPEN, CLOSED, REASONPROBESUCCEED},
+		{HALFOPEN, OPEN, REASONPROBEFAILED},
+		{HALFOPEN, RAMPUP, REASONPROBESUCCEED},
+		{RAMPUP, CLOSED, REASONRAMPEDUP},
+		{RAMPUP, OPEN, REASONTRIP},
+	}
+
+	for _, c := range cases {
+		if res := reasonOf(c.from, c.to); res != c.reason {
+			t.Fatalf("reason of %s -> %s should be %s, got: %s", c.from, c.to, c.reason, res)
+		}
+	}
+}
+
+func TestBreakerMethodChangeStatePublishTransition(t *testing.T) {
+	options := make(chan Transition, 3)
+	b := newBreaker(MetricsOptions{
+		Name:                "test",
+		MetricsRollingCount: 2,
+		RecoverInterval:     time.Hour,
+		OnStateChange:       func(t Transition) { options <- t },
+	}, DefaultPolicy)
+	defer b.Stop()
+
+	hooks := make(chan Transition, 3)
+	b.Subscribe(func(t Transition) { hooks <- t })
+
+	b.buckets[1].failed = 2
+	b.changeState(OPEN)
+	b.changeState(CLOSED)
+	b.changeState(HALFOPEN)
+	b.changeState(CLOSED)
+
+	for _, c := range []chan Transition{options, hooks} {
+		res := <-c
+		if res.Name != "test" || res.From != CLOSED || res.To != OPEN || res.Reason != REASONTRIP || res.At.IsZero() {
+			t.Fatalf("transition should be trip from CLOSED to OPEN, got: %+v", res)
+		}
+		if len(res.Counts) != 2 || res.Counts[1].Failed != 2 {
+			t.Fatalf("transition should carry counts before reset, got: %+v", res.Counts)
+		}
+
+		res = <-c
+		if res.From != OPEN || res.To != HALFOPEN || res.Reason != REASONRECOVERYTIMER {
+			t.Fatalf("transition should be from OPEN to HALFOPEN, got: %+v", res)
+		}
+
+		res = <-c
+		if res.From != HALFOPEN || res.To != CLOSED || res.Reason != REASONPROBESUCCEED || len(res.Counts) != 1 {
+			t.Fatalf("transition should be from HALFOPEN to CLOSED, got: %+v", res)
+		}
+	}
+}
+
+func TestBreakerMethodChangeStateShouldNotWaitForHooks(t *testing.T) {
+	b := newBreaker(MetricsOptions{RecoverInterval: time.Hour}, DefaultPolicy)
+	defer b.Stop()
+
+	block := make(chan struct{})
+	defer close(block)
+	b.Subscribe(func(Transition) { <-block })
+
+	b.changeState(OPEN)
+	b.changeState(HALFOPEN)
+
+	if b.State() != HALFOPEN {
+		t.Fatalf("state should be HALFOPEN, got: %v", b.State())
+	}
+}
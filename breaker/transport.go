@@ -0,0 +1,71 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Transport guards outbound requests with a breaker per key, the request
+// host by default. Responses with a 5xx status, connection errors and
+// timeouts are failures, other responses including 4xx are successes, and
+// requests canceled by their caller are not recorded.
+type Transport struct {
+	// http.DefaultTransport when nil.
+	Base http.RoundTripper
+
+	// Breakers of the keys, required.
+	Group *Group
+
+	// Key of a request, its URL host when nil.
+	Key func(*http.Request) string
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.URL.Host
+	if t.Key != nil {
+		key = t.Key(req)
+	}
+
+	b := t.Group.Get(key)
+
+	e, probe, err := b.admit()
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, &OpenError{Key: key}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+
+	switch {
+	case err == nil && resp.StatusCode >= 500:
+		b.complete(e, probe, FAILED, time.Since(start))
+	case err == nil:
+		b.complete(e, probe, SUCCEED, time.Since(start))
+	case errors.Is(err, context.Canceled) && req.Context().Err() == context.Canceled:
+		b.release(e, probe)
+	default:
+		b.complete(e, probe, transportOutcomeOf(req.Context(), err), time.Since(start))
+	}
+
+	return resp, err
+}
+
+func transportOutcomeOf(ctx context.Context, err error) Outcome {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return TIMEOUT
+	}
+
+	return outcomeOf(ctx, err)
+}
@@ -0,0 +1,106 @@
+package breaker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransportShouldRecordOutcomeByStatus(t *testing.T) {
+	g := NewGroup(GroupOptions{Defaults: MetricsOptions{MetricsRollingCount: 1}})
+	defer g.Stop()
+
+	status := http.StatusOK
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer s.Close()
+
+	client := &http.Client{Transport: &Transport{Group: g}}
+
+	for _, status = range []int{200, 404, 500, 503} {
+		resp, err := client.Get(s.URL)
+		if err != nil {
+			t.Fatalf("err should be nil, got: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	res := g.Get(s.Listener.Addr().String()).Snapshot().Total
+	if res.Succeeded != 2 || res.Failed != 2 {
+		t.Fatalf("4xx should succeed and 5xx should fail, got: %+v", res)
+	}
+}
+
+func TestTransportShouldRecordConnectionErrorAndTimeout(t *testing.T) {
+	g := NewGroup(GroupOptions{Defaults: MetricsOptions{MetricsRollingCount: 1}})
+	defer g.Stop()
+
+	block := make(chan struct{})
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer s.Close()
+	defer close(block)
+
+	client := &http.Client{
+		Transport: &Transport{Group: g, Key: func(*http.Request) string { return "key" }},
+		Timeout:   10 * time.Millisecond,
+	}
+
+	if _, err := client.Get(s.URL); err == nil {
+		t.Fatalf("err should be a timeout")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", s.URL, nil)
+	client.Do(req)
+
+	client.Transport.(*Transport).Base = roundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	client.Get(s.URL)
+
+	res := g.Get("key").Snapshot().Total
+	if res.TimedOut != 1 || res.Failed != 1 || res.Total() != 2 {
+		t.Fatalf("timeout and connection error should be recorded, canceled request should not, got: %+v", res)
+	}
+}
+
+func TestTransportShouldReturnOpenErrorWhenOpen(t *testing.T) {
+	g := NewGroup(GroupOptions{})
+	defer g.Stop()
+
+	b := g.Get("key")
+	b.cur.Store(newEpoch(OPEN))
+
+	called := false
+	client := &http.Client{Transport: &Transport{
+		Group: g,
+		Key:   func(*http.Request) string { return "key" },
+		Base: roundTripperFunc(func(*http.Request) (*http.Response, error) {
+			called = true
+			return nil, nil
+		}),
+	}}
+
+	_, err := client.Get("http://example.com")
+
+	var openErr *OpenError
+	if errors.As(err, &openErr) == false || openErr.Key != "key" || errors.Is(err, ErrOpen) == false {
+		t.Fatalf("err should be an OpenError for key, got: %v", err)
+	}
+	if called == true {
+		t.Fatalf("request should not be sent when open")
+	}
+}
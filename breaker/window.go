@@ -0,0 +1,81 @@
+package breaker
+
+type Counts struct {
+	Succeeded uint32
+	Failed    uint32
+	TimedOut  uint32
+	Rejected  uint32
+
+	// Calls of any outcome lasting at least the SlowCallThreshold.
+	Slow uint32
+
+	// Calls rejected by the breaker itself, not part of the Total.
+	ShortCircuited uint32
+}
+
+func (c Counts) Failures() uint32 {
+	return c.Failed + c.TimedOut + c.Rejected
+}
+
+func (c Counts) Total() uint32 {
+	return c.Succeeded + c.Failures()
+}
+
+func (c Counts) Add(o Counts) Counts {
+	return Counts{
+		Succeeded: c.Succeeded + o.Succeeded,
+		Failed:    c.Failed + o.Failed,
+		TimedOut:  c.TimedOut + o.TimedOut,
+		Rejected:  c.Rejected + o.Rejected,
+		Slow:      c.Slow + o.Slow,
+
+		ShortCircuited: c.ShortCircuited + o.ShortCircuited,
+	}
+}
+
+// Window is a read-only view of the rolling buckets, oldest first.
+type Window struct {
+	buckets []Counts
+}
+
+func NewWindow(buckets ...Counts) Window {
+	return Window{buckets: append([]Counts(nil), buckets...)}
+}
+
+func (w Window) Len() int {
+	return len(w.buckets)
+}
+
+func (w Window) Bucket(i int) Counts {
+	return w.buckets[i]
+}
+
+func (w Window) Total() Counts {
+	var res Counts
+
+	for _, c := range w.buckets {
+		res = res.Add(c)
+	}
+
+	return res
+}
+
+// Policy decides when a CLOSED breaker trips from its rolling window and
+// when a HALFOPEN breaker recovers from the counts of its trial calls.
+type Policy interface {
+	ShouldTrip(Window) Decision
+	ShouldRecover(Counts) Decision
+}
+
+type PolicyFuncs struct {
+	Trip    func(Window) Decision
+	Recover func(Counts) Decision
+}
+
+func (p PolicyFuncs) ShouldTrip(w Window) Decision {
+	return p.Trip(w)
+}
+
+func (p PolicyFuncs) ShouldRecover(c Counts) Decision {
+	return p.Recover(c)
+}
@@ -0,0 +1,72 @@
+package breaker
+
+import (
+	"testing"
+)
+
+func TestCountsMethods(t *testing.T) {
+	c := Counts{Succeeded: 1, Failed: 2, TimedOut: 3, Rejected: 4, Slow: 5}
+
+	if c.Failures() != 9 {
+		t.Fatalf("failures should be 9, got: %d", c.Failures())
+	}
+	if c.Total() != 10 {
+		t.Fatalf("total should be 10, got: %d", c.Total())
+	}
+	if res := c.Add(c); res != (Counts{Succeeded: 2, Failed: 4, TimedOut: 6, Rejected: 8, Slow: 10}) {
+		t.Fatalf("add error, got: %+v", res)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	buckets := []Counts{{Succeeded: 1}, {Failed: 2}, {TimedOut: 3}}
+	w := NewWindow(buckets...)
+	buckets[0].Succeeded = 10
+
+	if w.Len() != 3 {
+		t.Fatalf("length should be 3, got: %d", w.Len())
+	}
+	if w.Bucket(0).Succeeded != 1 || w.Bucket(2).TimedOut != 3 {
+		t.Fatalf("window should hold a copy of buckets, got: %+v, %+v", w.Bucket(0), w.Bucket(2))
+	}
+	if res := w.Total(); res != (Counts{Succeeded: 1, Failed: 2, TimedOut: 3}) {
+		t.Fatalf("total error, got: %+v", res)
+	}
+}
+
+func TestPolicyFuncs(t *testing.T) {
+	var p Policy = PolicyFuncs{
+		Trip:    func(w Window) Decision { return TRUE },
+		Recover: func(c Counts) Decision { return FALSE },
+	}
+
+	if p.ShouldTrip(Window{}) != TRUE || p.ShouldRecover(Counts{}) != FALSE {
+		t.Fatalf("policy funcs should be called")
+	}
+}
+
+func TestBreakerShouldPassWindowToPolicy(t *testing.T) {
+	var window Window
+	var counts Counts
+
+	b := newBreaker(MetricsOptions{MetricsRollingCount: 2}, PolicyFuncs{
+		Trip:    func(w Window) Decision { window = w; return HOLD },
+		Recover: func(c Counts) Decision { counts = c; return HOLD },
+	})
+
+	b.buckets[0].failed = 1
+	b.buckets[1].succeed = 2
+	b.evaluate()
+
+	if window.Len() != 2 || window.Bucket(0).Failed != 1 || window.Bucket(1).Succeeded != 2 {
+		t.Fatalf("policy should receive the rolling window, got: %+v", window)
+	}
+
+	b.cur.Store(newEpoch(HALFOPEN))
+	b.recoveryBucket.timeout = 3
+	b.evaluate()
+
+	if counts.TimedOut != 3 {
+		t.Fatalf("policy should receive the recovery counts, got: %+v", counts)
+	}
+}